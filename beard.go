@@ -1,8 +1,11 @@
 package beard
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
+	"io/ioutil"
 )
 
 func Render(fi File, d map[string]interface{}, fn PartialFunc) io.Reader {
@@ -15,31 +18,112 @@ func Render(fi File, d map[string]interface{}, fn PartialFunc) io.Reader {
 	return te
 }
 
+// RenderContext renders the file as Render does, stopping once ctx is done.
+// ctx is given to fn when finding partials and to Lambdas.
+func RenderContext(ctx context.Context,
+	fi File, d map[string]interface{}, fn PartialContextFunc) io.Reader {
+
+	te := Render(fi, d, nil).(*Template)
+	te.Context(ctx)
+	if fn != nil {
+		te.PartialContext(fn)
+	}
+
+	return te
+}
+
+// RenderEscaped renders the file as Render does, escaping variables with esc,
+// eg. NoEscape for plain text emails.
+func RenderEscaped(
+	fi File, d map[string]interface{}, fn PartialFunc, esc Escaper) io.Reader {
+
+	te := Render(fi, d, fn).(*Template)
+	te.Escaper(esc)
+
+	return te
+}
+
 // RenderInLayout allows a file to be rendered within a layout. Rendering is
 // handled by way of a partial, the partial syntax uses the keyword yield
-// eg. {{>yield}}
+// eg. {{>yield}}. Content captured by the file with {{#content_for name}} is
+// rendered with {{>yield name}}.
 func RenderInLayout(
 	la, fi File, d map[string]interface{}, fn PartialFunc) io.Reader {
 
+	return renderInLayouts(fi, d, fn, layoutPartialFunc, la)
+}
+
+// RenderInLayouts renders a file within a chain of layouts, given from the
+// outermost to the innermost layout. Each layout yields to the next, the
+// innermost yielding to the file. Unlike RenderInLayout, the PartialFunc is
+// used by all of the layouts as well as the file.
+func RenderInLayouts(
+	fi File, d map[string]interface{}, fn PartialFunc, layouts ...File) io.Reader {
+
+	return renderInLayouts(fi, d, fn, fn, layouts...)
+}
+
+// renderInLayouts wraps the file in each of the layouts, the layouts use
+// layoutFn as their PartialFunc. Content captured by any of the templates is
+// available to all of the layouts.
+func renderInLayouts(
+	fi File,
+	d map[string]interface{},
+	fn, layoutFn PartialFunc,
+	layouts ...File) io.Reader {
+
 	te := Render(fi, d, fn).(*Template)
+	te.content = make(map[string][]byte)
 
-	layo := &Template{
-		File: la,
-		Data: te.Data,
+	for i := len(layouts) - 1; i > -1; i-- {
+		layo := &Template{
+			File:    layouts[i],
+			Data:    te.Data,
+			content: te.content,
+			layout: &layout{
+				inner:   te,
+				content: te.content,
+			},
+		}
+		layo.Partial(layoutFn)
+
+		te = layo
 	}
-	layo.Partial(yieldFunc(te))
 
-	return layo
+	return te
+}
+
+// layoutPartialFunc is the PartialFunc of a layout, which only supports yield
+func layoutPartialFunc(path string) (io.Reader, error) {
+	return nil, errInvalidYieldTag
 }
 
-func yieldFunc(r io.Reader) PartialFunc {
-	return func(path string) (io.Reader, error) {
-		if path == "yield" {
-			return r, nil
+// layout renders the template within a layout. The template is rendered in full
+// on the first yield, so any content it captures is available to the layout
+// before the template itself is output.
+type layout struct {
+	inner   io.Reader
+	body    []byte
+	content map[string][]byte
+
+	rendered bool
+}
+
+func (l *layout) yield(name string) (io.Reader, error) {
+	if !l.rendered {
+		b, err := ioutil.ReadAll(l.inner)
+		if err != nil {
+			return nil, err
 		}
 
-		return nil, errInvalidYieldTag
+		l.body = b
+		l.rendered = true
+	}
+	if name == "" {
+		return bytes.NewReader(l.body), nil
 	}
+
+	return bytes.NewReader(l.content[name]), nil
 }
 
 var errInvalidYieldTag = errors.New("invalid yield tag")
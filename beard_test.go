@@ -67,3 +67,116 @@ func TestRenderInLayoutYieldError(t *testing.T) {
 		Then(bodyEquals(exp)).
 		And(errorIs(errInvalidYieldTag))
 }
+
+func TestRenderInLayoutContentFor(t *testing.T) {
+	layo := `<head>{{>yield scripts}}{{> yield  styles }}</head><body>{{>yield}}</body>`
+	html := `{{#content_for scripts}}<script src="{{a}}.js"></script>{{/content_for}}` +
+		`<h1>{{a}}</h1>` +
+		`{{#items}}{{#content_for scripts}}<script src="{{.}}.js"></script>{{/content_for}}{{/items}}` +
+		`{{>b}}`
+	data := map[string]interface{}{
+		"a":     "Hello",
+		"items": []string{"x", "y"},
+	}
+
+	var exp = `<head><script src="Hello.js"></script><script src="x.js"></script>` +
+		`<script src="y.js"></script><script src="z.js"></script></head>` +
+		`<body><h1>Hello</h1>b</body>`
+
+	tmpl := RenderInLayout(
+		bytes.NewReader([]byte(layo)),
+		bytes.NewReader([]byte(html)),
+		data,
+		func(path string) (io.Reader, error) {
+			if path == "b" {
+				return bytes.NewReader([]byte(`b{{#content_for scripts}}<script src="z.js"></script>{{/content_for}}`)), nil
+			}
+
+			t.Errorf("invalid path %s", path)
+			return nil, nil
+		},
+	).(*Template)
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestRenderContentForWithoutLayout(t *testing.T) {
+	html := `<h1>{{#content_for scripts}}{{a}}{{/content_for}}{{a}}</h1>`
+	data := map[string]interface{}{
+		"a": "Hello",
+	}
+
+	var exp = `<h1>Hello</h1>`
+
+	tmpl := Render(bytes.NewReader([]byte(html)), data, nil).(*Template)
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+
+	if got := string(tmpl.content["scripts"]); got != "Hello" {
+		t.Errorf("expected content to be captured, got %s", got)
+	}
+}
+
+func TestRenderContentForRequiresName(t *testing.T) {
+	html := `<h1>{{#content_for }}{{a}}{{/content_for}}</h1>`
+
+	tmpl := Render(bytes.NewReader([]byte(html)), nil, nil).(*Template)
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(`<h1>`)).
+		And(errorIs(errContentForName))
+}
+
+func TestRenderInLayouts(t *testing.T) {
+	site := `<html><head>{{>yield scripts}}</head>{{>nav}}{{>yield}}</html>`
+	section := `{{#content_for scripts}}<script src="{{section}}.js"></script>{{/content_for}}<section>{{>yield}}</section>`
+	html := `{{#content_for scripts}}<script src="page.js"></script>{{/content_for}}<h1>{{a}}</h1>`
+	data := map[string]interface{}{
+		"a":       "Hello",
+		"section": "blog",
+	}
+
+	var exp = `<html><head><script src="blog.js"></script><script src="page.js"></script></head>` +
+		`<nav>blog</nav><section><h1>Hello</h1></section></html>`
+
+	tmpl := RenderInLayouts(
+		bytes.NewReader([]byte(html)),
+		data,
+		func(path string) (io.Reader, error) {
+			if path == "nav" {
+				return bytes.NewReader([]byte(`<nav>{{section}}</nav>`)), nil
+			}
+
+			t.Errorf("invalid path %s", path)
+			return nil, nil
+		},
+		bytes.NewReader([]byte(site)),
+		bytes.NewReader([]byte(section)),
+	).(*Template)
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestRenderInLayoutsWithoutLayouts(t *testing.T) {
+	html := `<h1>{{a}}</h1>`
+	data := map[string]interface{}{
+		"a": "Hello",
+	}
+
+	tmpl := RenderInLayouts(bytes.NewReader([]byte(html)), data, nil).(*Template)
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(`<h1>Hello</h1>`)).
+		And(errorIs(nil))
+}
@@ -7,6 +7,22 @@ type block struct {
 	data   *Data
 
 	iterd int
+
+	// cond marks a conditional block, eg. {{#if}}, which does not provide a
+	// data scope
+	cond bool
+
+	// elsed marks that the block has reached one of its {{else}} tags
+	elsed bool
+
+	// final marks that the block has reached its final {{else}}
+	final bool
+
+	// taken marks that one of the block's contents has been rendered
+	taken bool
+
+	// active marks that the current else content should be rendered
+	active bool
 }
 
 func newBlock(tag string, c int, data *Data) *block {
@@ -17,12 +33,25 @@ func newBlock(tag string, c int, data *Data) *block {
 	}
 }
 
+// newCondBlock returns a block that renders it's content once when ok
+func newCondBlock(tag string, c int, ok bool) *block {
+	var data *Data
+	if ok {
+		data = &Data{Value: ok}
+	}
+
+	bl := newBlock(tag, c, data)
+	bl.cond = true
+
+	return bl
+}
+
 func (b *block) As(as ...string) {
 	b.as = as
 }
 
 func (b *block) Data() *Data {
-	if b.Skip() {
+	if b.Skip() || b.elsed || b.cond {
 		return nil
 	}
 
@@ -40,7 +69,18 @@ func (b *block) Data() *Data {
 	return data
 }
 
+// Skip checks to see if the current content of the block should be skipped.
+// Content after an {{else}} is only rendered when none of the block's previous
+// contents have been.
 func (b *block) Skip() bool {
+	if b.elsed {
+		return !b.active
+	}
+
+	return b.skip()
+}
+
+func (b *block) skip() bool {
 	if b.Inverted() {
 		return !b.Empty()
 	}
@@ -52,6 +92,61 @@ func (b *block) Inverted() bool {
 	return b.tag != "" && b.tag[0] == '^'
 }
 
+// Scoped checks to see if the block provides a data scope for lookups.
+func (b *block) Scoped() bool {
+	return !b.Inverted() && !b.elsed && !b.cond
+}
+
+// Else moves the block onto its final else content.
+func (b *block) Else() error {
+	if err := b.startElse(); err != nil {
+		return err
+	}
+
+	b.active = !b.taken
+	b.taken = true
+	b.final = true
+
+	return nil
+}
+
+// ElseIf moves the block onto an else if content. The condition fn is only
+// evaluated when none of the block's previous contents have been rendered.
+func (b *block) ElseIf(fn func() (bool, error)) error {
+	if err := b.startElse(); err != nil {
+		return err
+	}
+
+	if b.taken {
+		b.active = false
+
+		return nil
+	}
+
+	// the condition is evaluated from within the else content
+	b.active = true
+	ok, err := fn()
+	if err != nil {
+		return err
+	}
+	b.active = ok
+	b.taken = b.active
+
+	return nil
+}
+
+func (b *block) startElse() error {
+	if b.final {
+		return errDuplicateElse
+	}
+	if !b.elsed {
+		b.elsed = true
+		b.taken = !b.skip()
+	}
+
+	return nil
+}
+
 func (b *block) Empty() bool {
 	return b.data == nil || b.data.Len() == 0
 }
@@ -61,12 +156,18 @@ func (b *block) Empty() bool {
 func (b *block) Increment() int {
 	b.iterd++
 
+	// reset the else state for the next iteration
+	b.elsed = false
+	b.final = false
+	b.taken = false
+	b.active = false
+
 	return b.iterd
 }
 
 // Finished checks to see if a block has been completely iterated through.
 func (b *block) Finished() bool {
-	if b.Skip() {
+	if b.skip() {
 		return true
 	}
 	if b.Empty() {
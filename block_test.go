@@ -21,6 +21,79 @@ func Test_blockisFinished(t *testing.T) {
 	}
 }
 
+func Test_blockElse(t *testing.T) {
+	for _, v := range []struct {
+		tag  string
+		data *Data
+		skip bool
+	}{
+		{"#a", &Data{Value: []string{"a"}}, false},
+		{"#a", &Data{Value: []string{}}, true},
+		{"^a", &Data{Value: []string{"a"}}, true},
+		{"^a", nil, false},
+	} {
+		bl := newBlock(v.tag, 0, v.data)
+		if got := bl.Skip(); v.skip != got {
+			t.Errorf("expected %s to skip %t, got %t", v.tag, v.skip, got)
+		}
+		if err := bl.Else(); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if got := bl.Skip(); v.skip == got {
+			t.Errorf("expected %s else to skip %t, got %t", v.tag, !v.skip, got)
+		}
+		if bl.Scoped() {
+			t.Errorf("expected %s else to not be scoped", v.tag)
+		}
+		if err := bl.Else(); err != errDuplicateElse {
+			t.Errorf("expected duplicate else error, got %v", err)
+		}
+	}
+}
+
+func Test_blockElseIf(t *testing.T) {
+	bl := newCondBlock("#if", 0, false)
+	if !bl.Skip() {
+		t.Errorf("expected block to skip")
+	}
+
+	called := 0
+	ok := func(b bool) func() (bool, error) {
+		return func() (bool, error) {
+			called++
+
+			return b, nil
+		}
+	}
+
+	for _, v := range []struct {
+		cond bool
+		skip bool
+	}{
+		{false, true},
+		{true, false},
+		{true, true},
+	} {
+		if err := bl.ElseIf(ok(v.cond)); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if got := bl.Skip(); v.skip != got {
+			t.Errorf("expected else if to skip %t, got %t", v.skip, got)
+		}
+	}
+	if exp := 2; exp != called {
+		t.Errorf("expected conditions to be called %d times, got %d", exp, called)
+	}
+
+	bl.Else()
+	if !bl.Skip() {
+		t.Errorf("expected else to skip")
+	}
+	if err := bl.ElseIf(ok(true)); err != errDuplicateElse {
+		t.Errorf("expected duplicate else error, got %v", err)
+	}
+}
+
 func Test_blockgetValueDotOnSlice(t *testing.T) {
 	bl := newBlock("", 0, &Data{Value: []interface{}{
 		"a",
@@ -294,9 +367,11 @@ func Test_blockAsKeyValuegetValueOnMap(t *testing.T) {
 
 func Test_blockAsKeyValuegetKeyOnStruct(t *testing.T) {
 	type s struct {
-		a string
-		c string
-		e string
+		A string `beard:"a"`
+		C string `beard:"c"`
+		x string
+		E string `beard:"e"`
+		F string `beard:"-"`
 	}
 
 	for _, v := range []interface{}{
@@ -344,14 +419,16 @@ func Test_blockAsKeyValuegetKeyOnStruct(t *testing.T) {
 
 func Test_blockAsKeyValuegetValueOnStruct(t *testing.T) {
 	type s struct {
-		a string
-		c string
-		e string
+		A string `beard:"a"`
+		C string `beard:"c"`
+		x string
+		E string `beard:"e"`
+		F string `beard:"-"`
 	}
 
 	for _, v := range []interface{}{
-		s{"b", "d", "f"},
-		&s{"b", "d", "f"},
+		s{"b", "d", "x", "f", "g"},
+		&s{"b", "d", "x", "f", "g"},
 	} {
 		bl := newBlock("char", 0, &Data{Value: v})
 		bl.As("k", "v")
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stdin and environ are replaced by tests
+var (
+	stdin   io.Reader = os.Stdin
+	environ           = os.Environ
+)
+
+// loadData loads the data at path, a JSON or YAML file by its extension. -
+// reads JSON from stdin and an empty path is no data.
+func loadData(path string) (map[string]interface{}, error) {
+	var (
+		b   []byte
+		err error
+	)
+	switch path {
+	case "":
+		return make(map[string]interface{}), nil
+	case "-":
+		b, err = ioutil.ReadAll(stdin)
+		path = "stdin"
+	default:
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		v, err = parseYAML(path, b)
+	default:
+		v, err = parseJSON(path, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return make(map[string]interface{}), nil
+	}
+	d, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, errDataNotObject)
+	}
+
+	return d, nil
+}
+
+// parseJSON parses the JSON b read from path, whole numbers are parsed as
+// int64s so they are not rendered in exponent form
+func parseJSON(path string, b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		off, ok := jsonOffset(err)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		line, col := position(b, off)
+
+		return nil, &posError{path, line, col, err.Error()}
+	}
+
+	// only whitespace may follow the value
+	rest := bytes.TrimLeft(b[dec.InputOffset():], " \t\r\n")
+	if len(rest) > 0 {
+		line, col := position(b, len(b)-len(rest))
+
+		return nil, &posError{path, line, col, "unexpected data after the object"}
+	}
+
+	return numbers(v), nil
+}
+
+// numbers replaces the json.Numbers within v with int64s or float64s
+func numbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		n, _ := t.Float64()
+
+		return n
+
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = numbers(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = numbers(val)
+		}
+	}
+
+	return v
+}
+
+// jsonOffset returns the offset of a JSON syntax or type error
+func jsonOffset(err error) (int, bool) {
+	var (
+		serr *json.SyntaxError
+		terr *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &serr):
+		// the offset is after the invalid character
+		return int(serr.Offset) - 1, true
+	case errors.As(err, &terr):
+		return int(terr.Offset), true
+	}
+
+	return 0, false
+}
+
+// environment returns the environment variables by name
+func environment() map[string]interface{} {
+	env := make(map[string]interface{})
+	for _, kv := range environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	return env
+}
+
+var errDataNotObject = errors.New("data must be an object")
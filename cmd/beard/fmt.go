@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/nowk/beard"
+)
+
+// format formats templates, printing them to stdout unless they are written
+// back to their files, eg.
+//
+//	beard fmt -w -indent 2 ./templates/...
+func format(args []string, stdout, stderr io.Writer) error {
+	var (
+		fset = flag.NewFlagSet("fmt", flag.ContinueOnError)
+
+		write = fset.Bool("w", false,
+			"write the formatted templates back to their files")
+		list = fset.Bool("l", false,
+			"list the templates whose formatting differs")
+		indent = fset.Int("indent", 0,
+			"indent the content of sections by `n` spaces, this changes the whitespace of the output")
+		exts = fset.String("ext", "",
+			"comma separated `extensions` of the templates, eg. .mustache,.html")
+	)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprint(stderr, "usage: beard fmt [flags] path ...\n\n"+
+			"paths are templates or directories, dir/... includes sub directories\n\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return errUsage
+	}
+	if fset.NArg() == 0 {
+		fset.Usage()
+
+		return errUsage
+	}
+
+	files, err := templateFiles(fset.Args(), extensions(*exts))
+	if err != nil {
+		return err
+	}
+
+	opts := beard.FormatOptions{
+		Indent: strings.Repeat(" ", *indent),
+	}
+	for _, path := range files {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		b, err := beard.Format(src, opts)
+		if err != nil {
+			serr := err.(*beard.SyntaxError)
+			line, col := position(src, serr.Offset)
+
+			return &posError{path, line, col, serr.Msg}
+		}
+
+		changed := !bytes.Equal(src, b)
+		if *list && changed {
+			fmt.Fprintln(stdout, path)
+		}
+		if *write {
+			if changed {
+				fi, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				if err := ioutil.WriteFile(path, b, fi.Mode().Perm()); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+		if !*list {
+			stdout.Write(b)
+		}
+	}
+
+	return nil
+}
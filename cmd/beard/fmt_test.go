@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.mustache": "{{# items }}\n<li>{{ name }}</li>\n{{/ items }}\n",
+		"ok.mustache":   "{{title}}\n",
+	})
+
+	var (
+		page = filepath.Join(dir, "page.mustache")
+		exp  = "{{#items}}\n<li>{{name}}</li>\n{{/items}}\n"
+	)
+
+	code, stdout, stderr := runCmd("fmt", page)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+	}
+	if stdout != exp {
+		t.Errorf("expected %s, got %s", exp, stdout)
+	}
+
+	code, stdout, _ = runCmd("fmt", "-l", dir)
+	if code != 0 || stdout != page+"\n" {
+		t.Errorf("expected %s to be listed, got %d: %s", page, code, stdout)
+	}
+
+	code, _, _ = runCmd("fmt", "-w", "-indent", "2", dir)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	b, err := ioutil.ReadFile(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "{{#items}}\n  <li>{{name}}</li>\n{{/items}}\n"; string(b) != exp {
+		t.Errorf("expected %s, got %s", exp, b)
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bad.mustache": "<ul>\n  {{#items}}\n</ul>",
+	})
+
+	code, _, stderr := runCmd("fmt", filepath.Join(dir, "bad.mustache"))
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if exp := "bad.mustache:2:3: unclosed blocks"; !strings.Contains(stderr, exp) {
+		t.Errorf("expected %s, got %s", exp, stderr)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nowk/beard"
+)
+
+// diagnostic is a problem found within a template
+type diagnostic struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+	Msg    string `json:"message"`
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Msg)
+}
+
+// lint checks templates without data, printing a diagnostic for each problem
+// found, eg.
+//
+//	beard lint -partials ./partials ./templates/...
+func lint(args []string, stdout, stderr io.Writer) error {
+	var (
+		fset = flag.NewFlagSet("lint", flag.ContinueOnError)
+
+		partials = fset.String("partials", "",
+			"`dir`ectory of the partials, partials are not checked when empty")
+		exts = fset.String("ext", "",
+			"comma separated `extensions` of the templates, eg. .mustache,.html")
+		asJSON = fset.Bool("json", false, "print the diagnostics as JSON")
+	)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprint(stderr, "usage: beard lint [flags] path ...\n\n"+
+			"paths are templates or directories, dir/... includes sub directories\n\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return errUsage
+	}
+	if fset.NArg() == 0 {
+		fset.Usage()
+
+		return errUsage
+	}
+
+	filter := extensions(*exts)
+
+	files, err := templateFiles(fset.Args(), filter)
+	if err != nil {
+		return err
+	}
+
+	diags := []diagnostic{}
+	for _, path := range files {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var found func(string) bool
+		if *partials != "" {
+			found = partialFinder(*partials, append([]string{filepath.Ext(path)},
+				filter...))
+		}
+
+		for _, e := range beard.Lint(src, found) {
+			line, col := position(src, e.Offset)
+
+			diags = append(diags, diagnostic{path, line, col, e.Offset, e.Msg})
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diags); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diags {
+			fmt.Fprintln(stdout, d)
+		}
+	}
+	if len(diags) > 0 {
+		return errDiagnostics
+	}
+
+	return nil
+}
+
+// templateFiles returns the files of the paths, a path is a file, the files
+// of a directory or, when it ends in /..., the files of a directory and its
+// sub directories. Files of directories are filtered by their extensions and
+// hidden files are skipped.
+func templateFiles(paths, exts []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		recursive := strings.HasSuffix(p, "/...")
+		if recursive {
+			p = strings.TrimSuffix(p, "/...")
+		}
+
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+
+			continue
+		}
+
+		err = filepath.Walk(p, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != p && strings.HasPrefix(fi.Name(), ".") {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+			if fi.IsDir() {
+				if path != p && !recursive {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+			if hasExt(path, exts) {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// extensions splits a comma separated list of extensions
+func extensions(s string) []string {
+	var exts []string
+	for _, ext := range strings.Split(s, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			exts = append(exts, ext)
+		}
+	}
+
+	return exts
+}
+
+func hasExt(path string, exts []string) bool {
+	if len(exts) == 0 {
+		return true
+	}
+	for _, ext := range exts {
+		if filepath.Ext(path) == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// partialFinder reports whether a partial is found within dir, as named or
+// with one of the extensions
+func partialFinder(dir string, exts []string) func(string) bool {
+	return func(name string) bool {
+		path := filepath.Join(dir, name)
+		for _, ext := range append([]string{""}, exts...) {
+			fi, err := os.Stat(path + ext)
+			if err == nil && !fi.IsDir() {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// errDiagnostics is returned once the diagnostics of lint have been printed
+var errDiagnostics = errors.New("diagnostics")
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"templates/ok.mustache":         `{{#items as item}}{{item}}{{/items}}{{>header}}`,
+		"templates/bad.mustache":        "<ul>\n  {{#items as item}}\n  {{>footer}}\n</ul>",
+		"templates/users/list.mustache": "{{ }}",
+		"templates/.hidden.mustache":    "{{#a}}",
+		"templates/notes.txt":           "{{#a}}",
+		"partials/header.mustache":      `<h1>{{title}}</h1>`,
+	})
+
+	code, stdout, stderr := runCmd("lint",
+		"-partials", filepath.Join(dir, "partials"),
+		"-ext", ".mustache",
+		filepath.Join(dir, "templates")+"/...")
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d: %s", code, stderr)
+	}
+
+	var (
+		bad  = filepath.Join(dir, "templates", "bad.mustache")
+		list = filepath.Join(dir, "templates", "users", "list.mustache")
+
+		exp = strings.Join([]string{
+			bad + ":2:3: unclosed section #items",
+			bad + ":3:3: unknown partial footer",
+			list + ":1:1: empty tag",
+		}, "\n") + "\n"
+	)
+	if stdout != exp {
+		t.Errorf("expected %s, got %s", exp, stdout)
+	}
+
+	// the directory without /... does not include sub directories
+	code, stdout, _ = runCmd("lint", "-json",
+		filepath.Join(dir, "templates", "users"),
+		filepath.Join(dir, "templates", "ok.mustache"))
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+
+	var diags []diagnostic
+	if err := json.Unmarshal([]byte(stdout), &diags); err != nil {
+		t.Fatal(err)
+	}
+
+	var expDiags = []diagnostic{
+		{list, 1, 1, 0, "empty tag"},
+	}
+	if !reflect.DeepEqual(expDiags, diags) {
+		t.Errorf("expected %v, got %v", expDiags, diags)
+	}
+}
+
+func TestLintNoDiagnostics(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.mustache": `{{#items as item}}{{item}}{{/items}}`,
+	})
+
+	code, stdout, _ := runCmd("lint", "-json", dir)
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if exp := "[]\n"; stdout != exp {
+		t.Errorf("expected %s, got %s", exp, stdout)
+	}
+}
@@ -0,0 +1,107 @@
+// Command beard renders, checks and formats beard templates.
+//
+//	beard render [-data file] [-env] [-partials dir] [-layout file] template
+//	beard lint [-partials dir] [-ext extensions] [-json] path ...
+//	beard fmt [-w] [-l] [-indent n] [-ext extensions] path ...
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nowk/beard"
+)
+
+const usage = `usage: beard <command> [arguments]
+
+commands:
+	render	render a template with JSON or YAML data
+	lint	check templates for problems without rendering them
+	fmt	format the tags of templates
+
+run beard <command> -h for the arguments of a command
+`
+
+// command runs a sub command with its arguments
+type command func(args []string, stdout, stderr io.Writer) error
+
+var commands = map[string]command{
+	"render": render,
+	"lint":   lint,
+	"fmt":    format,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command named by args[0], returning the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "beard: unknown command %q\n\n%s", args[0], usage)
+
+		return 2
+	}
+
+	if err := cmd(args[1:], stdout, stderr); err != nil {
+		// usage and diagnostics have already been printed
+		if !errors.Is(err, errUsage) && !errors.Is(err, errDiagnostics) {
+			fmt.Fprintf(stderr, "beard: %s\n", err)
+		}
+
+		return 1
+	}
+
+	return 0
+}
+
+// posError is an error at a position within a file
+type posError struct {
+	path string
+	line int
+	col  int
+	msg  string
+}
+
+func (e *posError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.path, e.line, e.col, e.msg)
+}
+
+// checkTemplate checks the syntax of the template src read from path, the
+// error is positioned by line and column
+func checkTemplate(path string, src []byte) error {
+	err := beard.Check(src)
+	if err == nil {
+		return nil
+	}
+
+	serr := err.(*beard.SyntaxError)
+	line, col := position(src, serr.Offset)
+
+	return &posError{path, line, col, serr.Msg}
+}
+
+// position returns the line and column, from 1, of the offset within src
+func position(src []byte, offset int) (int, int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+
+	line := bytes.Count(src[:offset], []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(src[:offset], '\n')
+
+	return line, col
+}
+
+// errUsage is returned once a command has printed its usage
+var errUsage = errors.New("usage")
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nowk/beard"
+)
+
+// render renders a template to stdout, eg.
+//
+//	beard render -data data.json -partials ./partials -layout layout.mustache page.mustache
+func render(args []string, stdout, stderr io.Writer) error {
+	var (
+		fset = flag.NewFlagSet("render", flag.ContinueOnError)
+
+		dataPath = fset.String("data", "",
+			"JSON or YAML `file` of the data, - reads JSON from stdin")
+		env = fset.Bool("env", false,
+			"make the environment variables available as env, eg. {{env.HOME}}")
+		partials = fset.String("partials", "",
+			"`dir`ectory of the partials, defaults to the template's directory")
+		layout = fset.String("layout", "",
+			"layout `file` to render the template within")
+	)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprint(stderr, "usage: beard render [flags] template\n\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return errUsage
+	}
+	if fset.NArg() != 1 {
+		fset.Usage()
+
+		return errUsage
+	}
+	name := fset.Arg(0)
+
+	src, err := readTemplate(name)
+	if err != nil {
+		return err
+	}
+
+	d, err := loadData(*dataPath)
+	if err != nil {
+		return err
+	}
+	if *env {
+		d["env"] = environment()
+	}
+
+	files := make(sources)
+
+	dir := *partials
+	if dir == "" {
+		dir = filepath.Dir(name)
+	}
+	fn := checkedPartials(dir, filepath.Ext(name), files)
+
+	var layouts []beard.File
+	if *layout != "" {
+		la, err := readTemplate(*layout)
+		if err != nil {
+			return err
+		}
+		layouts = append(layouts, files.add(*layout, la))
+	}
+	te := beard.RenderInLayouts(
+		files.add(name, src), d, fn, layouts...).(*beard.Template)
+
+	w := bufio.NewWriter(stdout)
+	if _, err := io.Copy(w, te); err != nil {
+		w.Flush()
+
+		// syntax errors within partials are already positioned
+		if _, ok := err.(*posError); ok {
+			return err
+		}
+
+		return files.error(te, err)
+	}
+
+	return w.Flush()
+}
+
+// readTemplate reads the template at path and checks its syntax
+func readTemplate(path string) ([]byte, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemplate(path, src); err != nil {
+		return nil, err
+	}
+
+	return src, nil
+}
+
+// checkedPartials returns a PartialFunc finding partials within dir, as is or
+// with the extension ext. The syntax of each partial is checked when it is
+// first found and the partials are added to files.
+func checkedPartials(dir, ext string, files sources) beard.PartialFunc {
+	var exts []string
+	if ext != "" {
+		exts = append(exts, ext)
+	}
+
+	var (
+		fn = beard.FSPartials(os.DirFS(dir), beard.FSOptions{Extensions: exts})
+
+		mu   sync.Mutex
+		srcs = make(map[string][]byte)
+	)
+
+	return func(name string) (io.Reader, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := partialPath(dir, name, ext)
+		if src, ok := srcs[name]; ok {
+			return files.add(path, src), nil
+		}
+
+		r, err := fn(name)
+		if err != nil {
+			return nil, fmt.Errorf("partial %s: %w", name, err)
+		}
+		src, err := ioutil.ReadAll(r)
+		if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTemplate(path, src); err != nil {
+			return nil, err
+		}
+		srcs[name] = src
+
+		return files.add(path, src), nil
+	}
+}
+
+// partialPath returns the path of the partial name found within dir
+func partialPath(dir, name, ext string) string {
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil && ext != "" {
+		return path + ext
+	}
+
+	return path
+}
+
+// sources are the templates being rendered by their File, so that an error
+// can be positioned within them
+type sources map[beard.File]source
+
+type source struct {
+	path string
+	src  []byte
+}
+
+// add returns the template src, read from path, as a File
+func (s sources) add(path string, src []byte) beard.File {
+	f := bytes.NewReader(src)
+	s[f] = source{path, src}
+
+	return f
+}
+
+// error positions err at the tag te was rendering when it occurred
+func (s sources) error(te *beard.Template, err error) error {
+	f, offset, ok := te.ErrorOffset()
+	if !ok {
+		return err
+	}
+	so, ok := s[f]
+	if !ok {
+		return err
+	}
+	line, col := position(so.src, offset)
+
+	return &posError{so.path, line, col, err.Error()}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files to a temp directory, returning its path
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func runCmd(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRender(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"data.json":                       `{"title": "Hello", "count": 1234567, "items": [{"name": "a"}, {"name": "b"}]}`,
+		"data.yaml":                       "title: Hello\ncount: 1234567\nitems:\n  - name: a\n  - name: b\n",
+		"layout.mustache":                 `<html>{{>shared/nav}}{{>yield}}</html>`,
+		"page.mustache":                   `{{>shared/header}}{{#items}}<li>{{name}}</li>{{/items}}`,
+		"partials/shared/header.mustache": `<h1>{{title}} {{count}}</h1>`,
+		"partials/shared/nav.mustache":    `<nav>{{title}}</nav>`,
+	})
+
+	var exp = `<html><nav>Hello</nav><h1>Hello 1234567</h1><li>a</li><li>b</li></html>`
+
+	for _, data := range []string{"data.json", "data.yaml"} {
+		code, stdout, stderr := runCmd("render",
+			"-data", filepath.Join(dir, data),
+			"-partials", filepath.Join(dir, "partials"),
+			"-layout", filepath.Join(dir, "layout.mustache"),
+			filepath.Join(dir, "page.mustache"))
+		if code != 0 {
+			t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+		}
+		if stdout != exp {
+			t.Errorf("expected %s, got %s", exp, stdout)
+		}
+	}
+}
+
+func TestRenderSectionsAndElse(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"data.json": `{"items": ["a", "b"]}`,
+		"page.html": `{{#missing}}hidden{{/missing}}{{^items}}none{{/items}}` +
+			`{{#items}}<li>{{.}}</li>{{else}}empty{{/items}}!`,
+	})
+
+	code, stdout, stderr := runCmd("render",
+		"-data", filepath.Join(dir, "data.json"), filepath.Join(dir, "page.html"))
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+	}
+	if exp := "<li>a</li><li>b</li>!"; stdout != exp {
+		t.Errorf("expected %s, got %s", exp, stdout)
+	}
+}
+
+func TestRenderStdinAndEnv(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.txt": `{{name}} lives in {{env.HOME}}`,
+	})
+
+	stdin = strings.NewReader(`{"name": "Batman"}`)
+	environ = func() []string {
+		return []string{"HOME=/batcave", "EMPTY="}
+	}
+	defer func() {
+		stdin = os.Stdin
+		environ = os.Environ
+	}()
+
+	code, stdout, stderr := runCmd("render",
+		"-data", "-", "-env", filepath.Join(dir, "page.txt"))
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+	}
+	if exp := "Batman lives in /batcave"; stdout != exp {
+		t.Errorf("expected %s, got %s", exp, stdout)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bad.json":      "{\n  \"a\": 1,\n}",
+		"list.json":     `[1, 2]`,
+		"bad.yaml":      "a: 1\n b: 2",
+		"ok.json":       `{}`,
+		"items.json":    `{"items": [{"item": 1}]}`,
+		"unclosed.html": "<ul>\n  {{#items}}\n</ul>",
+		"page.html":     `{{>header}}`,
+		"header.html":   "<h1>\n{{#if a ==}}{{/if}}</h1>",
+		"missing.html":  `{{>footer}}`,
+		"outer.html":    "<div>\n{{#items}}{{>inner}}{{/items}}</div>",
+		"inner.html":    "<p>\n  {{item}} {{>nope}}</p>",
+	})
+
+	for _, v := range []struct {
+		data, template, err string
+	}{
+		{"bad.json", "page.html", "bad.json:3:1: invalid character '}'"},
+		{"list.json", "page.html", "list.json: data must be an object"},
+		{"bad.yaml", "page.html", "bad.yaml:2: mapping values are not allowed"},
+		{"ok.json", "unclosed.html", "unclosed.html:2:3: unclosed blocks"},
+		{"ok.json", "page.html", "header.html:2:11: unexpected end of condition"},
+		{"ok.json", "missing.html", "missing.html:1:1: partial footer:"},
+		{"items.json", "outer.html", "inner.html:2:12: partial nope:"},
+	} {
+		code, _, stderr := runCmd("render",
+			"-data", filepath.Join(dir, v.data), filepath.Join(dir, v.template))
+		if code != 1 {
+			t.Errorf("expected exit code 1, got %d", code)
+		}
+		if !strings.Contains(stderr, v.err) {
+			t.Errorf("expected %s, got %s", v.err, stderr)
+		}
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"unknown"},
+	} {
+		code, _, stderr := runCmd(args...)
+		if code != 2 {
+			t.Errorf("expected exit code 2, got %d", code)
+		}
+		if !strings.Contains(stderr, "usage: beard") {
+			t.Errorf("expected usage, got %s", stderr)
+		}
+	}
+
+	code, _, stderr := runCmd("render")
+	if code != 1 || !strings.Contains(stderr, "usage: beard render") {
+		t.Errorf("expected render usage, got %d: %s", code, stderr)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLine matches the line of a YAML error, eg.
+// yaml: line 2: did not find expected key
+var yamlLine = regexp.MustCompile(`line (\d+): (.*)`)
+
+// parseYAML parses the YAML b read from path, errors are positioned by line
+func parseYAML(path string, b []byte) (interface{}, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			return nil, fmt.Errorf("%s:%s: %s", path, m[1], m[2])
+		}
+
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return yamlValues(v), nil
+}
+
+// yamlValues replaces the maps within v with map[string]interface{}s, the
+// whole numbers with int64s and timestamps with strings, as data parsed from
+// JSON
+func yamlValues(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = yamlValues(val)
+		}
+
+		return t
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = yamlValues(val)
+		}
+
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = yamlValues(val)
+		}
+
+		return t
+
+	case int:
+		return int64(t)
+	case uint64:
+		if t <= math.MaxInt64 {
+			return int64(t)
+		}
+
+		return float64(t)
+
+	case time.Time:
+		if t.Equal(t.Truncate(24*time.Hour)) && t.Location() == time.UTC {
+			return t.Format("2006-01-02")
+		}
+
+		return t.Format(time.RFC3339Nano)
+	}
+
+	return v
+}
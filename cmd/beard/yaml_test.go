@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	src := `# site
+title: "Hello: World"
+count: 3
+big: 18446744073709551615
+ratio: 0.5
+draft: false
+date: 2001-01-02
+time: 2001-01-02T15:04:05Z
+empty:
+tags: [a, 'b c', 1]
+codes: {1: one, true: yes}
+users:
+  - name: Robin   # sidekick
+    age: 17
+  - name: Alfred
+body: |
+  line one
+    indented
+`
+
+	var exp = map[string]interface{}{
+		"title": "Hello: World",
+		"count": int64(3),
+		"big":   float64(18446744073709551615),
+		"ratio": 0.5,
+		"draft": false,
+		"date":  "2001-01-02",
+		"time":  "2001-01-02T15:04:05Z",
+		"empty": nil,
+		"tags":  []interface{}{"a", "b c", int64(1)},
+		"codes": map[string]interface{}{"1": "one", "true": "yes"},
+		"users": []interface{}{
+			map[string]interface{}{"name": "Robin", "age": int64(17)},
+			map[string]interface{}{"name": "Alfred"},
+		},
+		"body": "line one\n  indented\n",
+	}
+
+	got, err := parseYAML("data.yaml", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("expected %#v, got %#v", exp, got)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, v := range []struct {
+		giv string
+		err string
+	}{
+		{"a: 1\n  b: 2", "data.yaml:2: "},
+		{"a: 1\na: 2", "data.yaml:2: "},
+		{"a: [1, 2", "data.yaml:1: "},
+	} {
+		_, err := parseYAML("data.yaml", []byte(v.giv))
+		if err == nil || !strings.HasPrefix(err.Error(), v.err) {
+			t.Errorf("expected %s, got %v", v.err, err)
+		}
+	}
+}
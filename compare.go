@@ -0,0 +1,186 @@
+package beard
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CompareFunc compares a and b. It returns a negative number when a sorts
+// before b, a positive number when a sorts after b and 0 when they are equal.
+type CompareFunc func(a, b interface{}) int
+
+// comparators are the built in CompareFuncs available to the sort modifier
+var comparators = map[string]CompareFunc{
+	"auto":    compareAuto,
+	"string":  compareString,
+	"numeric": compareNumeric,
+	"natural": compareNatural,
+}
+
+// compareAuto compares numbers numerically and everything else as strings.
+// nil values sort first.
+func compareAuto(a, b interface{}) int {
+	if n, ok := compare(a, b); ok {
+		return n
+	}
+	if n, ok := compareNil(a, b); ok {
+		return n
+	}
+
+	return compareString(a, b)
+}
+
+func compareString(a, b interface{}) int {
+	x, y := sprint(a), sprint(b)
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
+
+// compareNumeric compares values as numbers, strings are parsed as numbers.
+// Values that are not numbers sort after numbers.
+func compareNumeric(a, b interface{}) int {
+	x, xok := parseFloat(a)
+	y, yok := parseFloat(b)
+
+	switch {
+	case !xok && !yok:
+		return compareString(a, b)
+	case !xok:
+		return 1
+	case !yok:
+		return -1
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
+
+// compareNatural compares values as strings, comparing runs of digits
+// numerically, eg. a2 sorts before a10.
+func compareNatural(a, b interface{}) int {
+	x, y := sprint(a), sprint(b)
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		if isDigit(x[i]) && isDigit(y[j]) {
+			m, n := i, j
+			for m < len(x) && isDigit(x[m]) {
+				m++
+			}
+			for n < len(y) && isDigit(y[n]) {
+				n++
+			}
+
+			// compare the runs without their leading zeros, a longer run is
+			// the larger number
+			p, q := trimZeros(x[i:m]), trimZeros(y[j:n])
+			if len(p) != len(q) {
+				return len(p) - len(q)
+			}
+			if p != q {
+				if p < q {
+					return -1
+				}
+
+				return 1
+			}
+
+			i, j = m, n
+
+			continue
+		}
+		if x[i] != y[j] {
+			return int(x[i]) - int(y[j])
+		}
+
+		i++
+		j++
+	}
+
+	return (len(x) - i) - (len(y) - j)
+}
+
+func trimZeros(s string) string {
+	i := 0
+	for i < len(s)-1 && s[i] == '0' {
+		i++
+	}
+
+	return s[i:]
+}
+
+func compareNil(a, b interface{}) (int, bool) {
+	a, b = indirect(a), indirect(b)
+
+	switch {
+	case a == nil && b == nil:
+		return 0, true
+	case a == nil:
+		return -1, true
+	case b == nil:
+		return 1, true
+	}
+
+	return 0, false
+}
+
+func parseFloat(v interface{}) (float64, bool) {
+	v = indirect(v)
+	if f, ok := toFloat(v); ok {
+		return f, true
+	}
+	if s, ok := toString(v); ok {
+		f, err := strconv.ParseFloat(s, 64)
+
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+func sprint(v interface{}) string {
+	v = indirect(v)
+	if v == nil {
+		return ""
+	}
+	if s, ok := toString(v); ok {
+		return s
+	}
+
+	return fmt.Sprint(v)
+}
+
+// Comparator registers a CompareFunc by name to be used with the sort
+// modifier, eg. {{#items sort:name fr}}. Partials use the comparators of their
+// parent template.
+func (t *Template) Comparator(name string, fn CompareFunc) {
+	if t.comparators == nil {
+		t.comparators = make(map[string]CompareFunc)
+	}
+
+	t.comparators[name] = fn
+}
+
+// comparator finds a CompareFunc by name, looking up the partial chain before
+// looking at the built in comparators.
+func (t *Template) comparator(name string) (CompareFunc, bool) {
+	for te := t; te != nil; te = te.parent {
+		if fn, ok := te.comparators[name]; ok {
+			return fn, true
+		}
+	}
+
+	fn, ok := comparators[name]
+
+	return fn, ok
+}
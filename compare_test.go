@@ -0,0 +1,56 @@
+package beard
+
+import (
+	"testing"
+)
+
+func Test_comparators(t *testing.T) {
+	for _, v := range []struct {
+		fn   string
+		a, b interface{}
+		exp  int
+	}{
+		{"auto", 2, 10, -1},
+		{"auto", "2", "10", 1},
+		{"auto", nil, "a", -1},
+		{"auto", 1.5, int64(1), 1},
+		{"string", 2, 10, 1},
+		{"numeric", "2", "10", -1},
+		{"numeric", "a", 1, 1},
+		{"numeric", "3", 3, 0},
+		{"natural", "a2", "a10", -1},
+		{"natural", "a10", "a2", 1},
+		{"natural", "a02", "a2", 0},
+		{"natural", "a2b", "a2c", -1},
+		{"natural", "a", "ab", -1},
+	} {
+		n := comparators[v.fn](v.a, v.b)
+		if n < 0 {
+			n = -1
+		} else if n > 0 {
+			n = 1
+		}
+		if v.exp != n {
+			t.Errorf("expected %s(%v, %v) to be %d, got %d", v.fn, v.a, v.b, v.exp, n)
+		}
+	}
+}
+
+func TestTemplateComparatorIsInherited(t *testing.T) {
+	tmpl := &Template{}
+	tmpl.Comparator("len", func(a, b interface{}) int {
+		return len(sprint(a)) - len(sprint(b))
+	})
+
+	partial := &Template{parent: tmpl}
+
+	if _, ok := partial.comparator("len"); !ok {
+		t.Errorf("expected partial to find parent comparator")
+	}
+	if _, ok := partial.comparator("natural"); !ok {
+		t.Errorf("expected partial to find built in comparator")
+	}
+	if _, ok := partial.comparator("unknown"); ok {
+		t.Errorf("expected partial to not find unknown comparator")
+	}
+}
@@ -0,0 +1,75 @@
+package beard
+
+import (
+	"context"
+	"io"
+)
+
+// PartialContextFunc is a PartialFunc which is given the context of the render
+type PartialContextFunc func(context.Context, string) (io.Reader, error)
+
+// Lambda is a func value which is called for its value when it is rendered as
+// a variable or a block. It is given the context of the render.
+type Lambda func(context.Context) (interface{}, error)
+
+// Context sets the context of rendering the template. Rendering stops with the
+// context's error once it is done, it is checked on each Read and each block
+// iteration. Partials use the context of their parent template.
+func (t *Template) Context(ctx context.Context) {
+	t.ctx = ctx
+}
+
+// PartialContext sets a PartialContextFunc to find partials with
+func (t *Template) PartialContext(fn PartialContextFunc) {
+	t.Partial(func(name string) (io.Reader, error) {
+		return fn(t.context(), name)
+	})
+}
+
+// context returns the context of the template, or of its closest parent. It
+// defaults to context.Background.
+func (t *Template) context() context.Context {
+	for te := t; te != nil; te = te.parent {
+		if te.ctx != nil {
+			return te.ctx
+		}
+	}
+
+	return context.Background()
+}
+
+// ctxErr returns the error of the template's context once it is done
+func (t *Template) ctxErr() error {
+	for te := t; te != nil; te = te.parent {
+		if te.ctx != nil {
+			return te.ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// call calls the value of d when it is a Lambda, returning its value
+func (t *Template) call(d *Data) (*Data, error) {
+	if d == nil {
+		return nil, nil
+	}
+
+	var fn Lambda
+	switch v := d.Value.(type) {
+	case Lambda:
+		fn = v
+	case func(context.Context) (interface{}, error):
+		fn = v
+
+	default:
+		return d, nil
+	}
+
+	v, err := fn(t.context())
+	if err != nil {
+		return nil, err
+	}
+
+	return newData(v), nil
+}
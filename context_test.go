@@ -0,0 +1,184 @@
+package beard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+type ctxKey struct{}
+
+func TestRenderContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "Batman")
+
+	html := `<h1>{{>name}}</h1>{{#items}}<p>{{.}}</p>{{/items}}{{user}}`
+	data := map[string]interface{}{
+		"items": Lambda(func(ctx context.Context) (interface{}, error) {
+			return []string{"a", "b"}, nil
+		}),
+		"user": func(ctx context.Context) (interface{}, error) {
+			return ctx.Value(ctxKey{}), nil
+		},
+	}
+
+	var exp = `<h1>Batman</h1><p>a</p><p>b</p>Batman`
+
+	tmpl := RenderContext(ctx, bytes.NewReader([]byte(html)), data,
+		func(ctx context.Context, path string) (io.Reader, error) {
+			if path != "name" {
+				t.Errorf("invalid partial %s", path)
+			}
+
+			return bytes.NewReader([]byte(ctx.Value(ctxKey{}).(string))), nil
+		},
+	).(*Template)
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestRenderContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tmpl := RenderContext(ctx, bytes.NewReader([]byte(`<h1>{{a}}</h1>`)), nil, nil)
+
+	b, err := ioutil.ReadAll(tmpl)
+	if err != context.Canceled {
+		t.Errorf("expected %s, got %v", context.Canceled, err)
+	}
+	if len(b) != 0 {
+		t.Errorf("expected nothing to be rendered, got %s", b)
+	}
+}
+
+func TestRenderContextCanceledDuringBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	items := make([]int, 100)
+	calls := 0
+
+	html := `{{#items}}{{next}}{{/items}}`
+	data := map[string]interface{}{
+		"items": items,
+		"next": Lambda(func(context.Context) (interface{}, error) {
+			if calls++; calls == 3 {
+				cancel()
+			}
+
+			return "x", nil
+		}),
+	}
+
+	tmpl := RenderContext(ctx, bytes.NewReader([]byte(html)), data, nil)
+
+	b := make([]byte, 4096)
+	var (
+		n   int
+		err error
+	)
+	for err == nil {
+		var m int
+		m, err = tmpl.Read(b[n:])
+		n += m
+	}
+	if err != context.Canceled {
+		t.Errorf("expected %s, got %v", context.Canceled, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected rendering to stop after 3 calls, got %d", calls)
+	}
+}
+
+func TestTemplateLambdaError(t *testing.T) {
+	errLambda := errors.New("lambda error")
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(`<h1>{{a}}</h1>`)),
+		Data: &Data{Value: map[string]interface{}{
+			"a": Lambda(func(context.Context) (interface{}, error) {
+				return nil, errLambda
+			}),
+		}},
+	}
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(`<h1>`)).
+		And(errorIs(errLambda))
+}
+
+func TestTemplateLambdaConditionsAndModifiers(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "Batman")
+
+	lambda := func(v interface{}) Lambda {
+		return func(ctx context.Context) (interface{}, error) {
+			if ctx.Value(ctxKey{}) != "Batman" {
+				t.Errorf("expected the template's context")
+			}
+
+			return v, nil
+		}
+	}
+	data := map[string]interface{}{
+		"no":   lambda(false),
+		"name": lambda("Bruce"),
+		"items": []interface{}{
+			map[string]interface{}{"n": lambda(2), "on": lambda(true)},
+			map[string]interface{}{"n": lambda(1), "on": lambda(true)},
+			map[string]interface{}{"n": lambda(3), "on": lambda(false)},
+		},
+	}
+
+	html := `{{#if no}}yes{{else if name == "Bruce"}}{{name}}{{/if}}` +
+		`{{#items where:on sort:n}}{{n}}{{/items}}{{>p label=name}}`
+
+	tmpl := RenderContext(ctx, bytes.NewReader([]byte(html)), data,
+		func(ctx context.Context, path string) (io.Reader, error) {
+			return bytes.NewReader([]byte(`({{label}})`)), nil
+		},
+	)
+
+	b, err := ioutil.ReadAll(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `Bruce12(Bruce)`; string(b) != exp {
+		t.Errorf("expected %s, got %s", exp, b)
+	}
+}
+
+func TestTemplateLambdaErrorInCondition(t *testing.T) {
+	errLambda := errors.New("lambda error")
+
+	fn := Lambda(func(context.Context) (interface{}, error) {
+		return nil, errLambda
+	})
+
+	for _, html := range []string{
+		`{{#if a}}{{/if}}`,
+		`{{#if !b}}{{else if a}}{{/if}}`,
+		`{{#items where:a}}{{/items}}`,
+		`{{#items sort:a}}{{/items}}`,
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: map[string]interface{}{
+				"a":     fn,
+				"b":     true,
+				"items": []interface{}{map[string]interface{}{"a": fn}},
+			}},
+		}
+
+		_, err := ioutil.ReadAll(tmpl)
+		if err != errLambda {
+			t.Errorf("expected %s for %s, got %v", errLambda, html, err)
+		}
+	}
+}
@@ -2,12 +2,22 @@ package beard
 
 import (
 	"fmt"
+	"html/template"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// OrderedMap is implemented by map types that maintain the order of their
+// keys, eg. insertion order. Key/value blocks iterate over an OrderedMap in the
+// order of Keys rather than sorting the keys.
+type OrderedMap interface {
+	Keys() []string
+	Get(string) (interface{}, bool)
+}
+
 type Data struct {
 	Value interface{}
 
@@ -44,18 +54,22 @@ func (d *Data) As(as ...string) {
 func (d *Data) Get(k string) *Data {
 	if d.isKeyValue && d.k != "" && d.k == k {
 		return &Data{
-			Value: d.getKey(d.k),
+			Value: d.getKey(),
 		}
 	}
 
-	if d.isKeyValue && d.as != "" && d.as == k {
-		switch v := d.getKey(d.k).(type) {
-		case reflect.Value:
-			k = v.String()
-		case string:
-			k = v
-		default:
-			// TODO handle
+	// the value name, and paths on it, look up the value of the current key
+	if d.isKeyValue && d.as != "" {
+		if d.as == k {
+			return newData(d.getKeyValue())
+		}
+		if strings.HasPrefix(k, d.as+".") {
+			v := newData(d.getKeyValue())
+			if v == nil {
+				return nil
+			}
+
+			return v.Get(k[len(d.as)+1:])
 		}
 	}
 
@@ -73,6 +87,15 @@ func (d *Data) Get(k string) *Data {
 	return nil
 }
 
+// newData returns a Data for v, nil values return nil
+func newData(v interface{}) *Data {
+	if v == nil {
+		return nil
+	}
+
+	return &Data{Value: v}
+}
+
 // Len returns the length of the data object. Any non nil object that is not a
 // slice will be returned with a value of 1
 func (d *Data) Len() int {
@@ -104,19 +127,20 @@ func (d *Data) IsKeys() (int, bool) {
 	if d.Value == nil {
 		return 0, false
 	}
-	v, ok := d.Value.(reflect.Value)
-	if !ok {
-		v = reflect.ValueOf(d.Value)
+	if d.keys != nil {
+		return len(d.keys), true
 	}
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
+	if om, ok := d.Value.(OrderedMap); ok {
+		return len(om.Keys()), true
 	}
 
+	v := reflectValue(d.Value)
+
 	switch v.Kind() {
 	case reflect.Map:
-		return len(v.MapKeys()), true
+		return v.Len(), true
 	case reflect.Struct:
-		return v.NumField(), true
+		return len(structFields(v.Type())), true
 	}
 
 	return 0, false
@@ -160,8 +184,16 @@ func (d *Data) Bytes() []byte {
 		return strconv.AppendBool(b, t)
 	case []byte:
 		return t
+	case HTML:
+		return []byte(t)
+	case template.HTML:
+		return []byte(t)
 	case reflect.Value:
-		return []byte(t.String())
+		if t.CanInterface() {
+			return (&Data{Value: t.Interface()}).Bytes()
+		}
+
+		return nil
 
 	default:
 		return []byte(fmt.Sprintf("%s", t))
@@ -170,8 +202,9 @@ func (d *Data) Bytes() []byte {
 	return nil
 }
 
+// keyName orders keys by their type, eg. numeric keys are ordered numerically
 var keyName = func(r1, r2 *reflect.Value) bool {
-	return r1.String() < r2.String()
+	return compareAuto(*r1, *r2) < 0
 }
 
 type by func(*reflect.Value, *reflect.Value) bool
@@ -202,42 +235,69 @@ func (k *keySorter) Less(i, j int) bool {
 	return k.by(&k.keys[i], &k.keys[j])
 }
 
-func (d *Data) getKey(k string) interface{} {
-	// look at the parent's data set to figure out the type
-	val, ok := d.block.data.Value.(reflect.Value)
-	if !ok {
-		val = reflect.ValueOf(d.block.data.Value)
-	}
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
+// getKey returns the key of the current iteration. Maps return their key,
+// structs their field name, all else the index.
+func (d *Data) getKey() interface{} {
+	src := d.block.data.Value
+
+	if om, ok := src.(OrderedMap); ok {
+		if d.keys == nil {
+			d.keys = orderedKeys(om)
+		}
+
+		return d.keys[d.i].Interface()
 	}
 
-	var v interface{}
+	val := reflectValue(src)
 
 	switch val.Kind() {
 	case reflect.Map:
 		// save keys to struct and access keys via saved value, else we run into
 		// issues with map keys not maintaining order.
 		if d.keys == nil {
-			keys := val.MapKeys()
-
-			by(keyName).Sort(keys)
-
-			d.keys = keys
+			d.keys = d.mapKeys(val)
 		}
 
-		v = d.keys[d.i]
+		return valueInterface(d.keys[d.i])
 	case reflect.Struct:
-		f := val.Type().Field(d.i)
+		return structFields(val.Type())[d.i].name
+	}
 
-		v = f.Name
+	// we only find keys on maps and structs, all else will return the index
+	return d.i
+}
 
-	default:
-		// we only find keys on maps and structs, all else will return the index
-		v = d.i
+// getKeyValue returns the value of the current iteration's key
+func (d *Data) getKeyValue() interface{} {
+	src := d.block.data.Value
+	key := d.getKey()
+
+	if om, ok := src.(OrderedMap); ok {
+		v, _ := om.Get(key.(string))
+
+		return v
 	}
 
-	return v
+	val := reflectValue(src)
+
+	switch val.Kind() {
+	case reflect.Map:
+		return valueInterface(val.MapIndex(d.keys[d.i]))
+	case reflect.Struct:
+		return valueInterface(val.FieldByIndex(structFields(val.Type())[d.i].index))
+	}
+
+	// lists are iterated as their index and the item itself
+	return d.Value
+}
+
+// mapKeys returns the keys of the map val in the order they are iterated
+func (d *Data) mapKeys(val reflect.Value) []reflect.Value {
+	keys := val.MapKeys()
+
+	by(keyName).Sort(keys)
+
+	return keys
 }
 
 // getValue finds the value of the path within source.
@@ -253,19 +313,35 @@ func (d *Data) getValue(path string, source interface{}) interface{} {
 		return nil
 	}
 
-	v, ok := source.(reflect.Value)
-	if !ok {
-		v = reflect.ValueOf(source)
-	}
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
+	if om, ok := source.(OrderedMap); ok {
+		v, ok := om.Get(tr)
+		if !ok {
+			return nil
+		}
+		if br != "" {
+			return d.getValue(br, v)
+		}
+
+		return v
 	}
 
+	v := reflectValue(source)
+
 	switch v.Kind() {
 	case reflect.Map:
-		v = v.MapIndex(reflect.ValueOf(tr))
+		k := reflect.ValueOf(tr)
+		if !k.Type().AssignableTo(v.Type().Key()) {
+			return nil
+		}
+
+		v = v.MapIndex(k)
 	case reflect.Struct:
-		v = v.FieldByName(tr)
+		f, ok := structField(v.Type(), tr)
+		if !ok {
+			return nil
+		}
+
+		v = v.FieldByIndex(f.index)
 
 	default:
 		return nil
@@ -290,6 +366,31 @@ func (d *Data) getValue(path string, source interface{}) interface{} {
 
 const pathDelim = '.'
 
+// reflectValue returns the reflect.Value of v, dereferencing pointers
+func reflectValue(v interface{}) reflect.Value {
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	return rv
+}
+
+// valueInterface returns the interface of v if it can, else it returns v
+func valueInterface(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.CanInterface() {
+		return v.Interface()
+	}
+
+	return v
+}
+
 func splitpath(path string) (string, string) {
 	i := strings.IndexByte(path, pathDelim)
 	if i != -1 {
@@ -298,3 +399,73 @@ func splitpath(path string) (string, string) {
 
 	return path, ""
 }
+
+// orderedKeys returns the keys of an OrderedMap
+func orderedKeys(om OrderedMap) []reflect.Value {
+	keys := om.Keys()
+
+	vals := make([]reflect.Value, 0, len(keys))
+	for _, k := range keys {
+		vals = append(vals, reflect.ValueOf(k))
+	}
+
+	return vals
+}
+
+// field is an iterable struct field
+type field struct {
+	name  string
+	index []int
+}
+
+// fieldCache caches the iterable fields of struct types
+var fieldCache sync.Map
+
+// structFields returns the fields of a struct type that are iterated over,
+// which are its exported fields. A field's name can be changed with a beard
+// tag, eg. `beard:"name"`, or the field can be omitted with `beard:"-"`.
+func structFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+
+	n := t.NumField()
+
+	fields := make([]field, 0, n)
+	for i := 0; i < n; i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("beard"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, field{
+			name:  name,
+			index: f.Index,
+		})
+	}
+
+	fieldCache.Store(t, fields)
+
+	return fields
+}
+
+// structField finds an iterable field by its name
+func structField(t reflect.Type, name string) (field, bool) {
+	for _, f := range structFields(t) {
+		if f.name == name {
+			return f, true
+		}
+	}
+
+	return field{}, false
+}
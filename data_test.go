@@ -92,13 +92,9 @@ func TestDataGetStructFields(t *testing.T) {
 		}
 	}
 
-	{
-		var exp = "!"
-
-		b := d.Get("d.E.f")
-		if got := string(b.Bytes()); exp != got {
-			t.Errorf("expected %s, got %s", exp, got)
-		}
+	// unexported fields are not iterated over, so they can't be looked up
+	if b := d.Get("d.E.f"); b != nil {
+		t.Errorf("expected nil, got %s", b)
 	}
 }
 
@@ -239,3 +235,65 @@ func TestDataByteRepresentations(t *testing.T) {
 		}
 	}
 }
+
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) Keys() []string {
+	return m.keys
+}
+
+func (m *orderedMap) Get(k string) (interface{}, bool) {
+	v, ok := m.values[k]
+
+	return v, ok
+}
+
+func TestDataGetOrderedMap(t *testing.T) {
+	data := map[string]interface{}{
+		"a": &orderedMap{
+			keys: []string{"b"},
+			values: map[string]interface{}{
+				"b": map[string]interface{}{
+					"c": "Hello",
+				},
+			},
+		},
+	}
+
+	d := Data{Value: data}
+
+	if got := d.Get("a.b.c"); got == nil || string(got.Bytes()) != "Hello" {
+		t.Errorf("expected Hello, got %v", got)
+	}
+	if got := d.Get("a.d"); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
+
+func TestDataGetStructTaggedFields(t *testing.T) {
+	data := map[string]interface{}{
+		"a": struct {
+			B string `beard:"b"`
+		}{"Hello"},
+		"c": map[int]string{1: "World"},
+	}
+
+	d := Data{Value: data}
+
+	if got := d.Get("a.b"); got == nil || string(got.Bytes()) != "Hello" {
+		t.Errorf("expected Hello, got %v", got)
+	}
+
+	// a tagged field is only found by its tag name
+	if got := d.Get("a.B"); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+
+	// keys that are not of the map's key type are not found
+	if got := d.Get("c.1"); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
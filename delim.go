@@ -55,7 +55,6 @@ func (d *Rdelim) Value() []byte {
 }
 
 func matchDelim(b, del []byte) ([]byte, matchLevel) {
-	lenb := len(b)
 	lendel := len(del)
 
 	// find the delim in full
@@ -63,15 +62,9 @@ func matchDelim(b, del []byte) ([]byte, matchLevel) {
 		return b[:i+lendel], exMatch
 	}
 
-	// find a partial match
-	z := lendel - 1
-	for ; z > 0; z-- {
-		i := bytes.Index(b, del[:z])
-		if i == -1 {
-			continue
-		}
-		// match must be at the end of the byte array
-		if i+z == lenb {
+	// find a partial match, which must be at the end of the byte array
+	for z := lendel - 1; z > 0; z-- {
+		if bytes.HasSuffix(b, del[:z]) {
 			return b, paMatch
 		}
 	}
@@ -20,6 +20,8 @@ func Test_ldelim(t *testing.T) {
 		{"hello {c}", "{{", "hello {c}", noMatch},
 		{"hello {c}", "{{{", "hello {c}", noMatch},
 		{"hello {{c}}", "{{{", "hello {{c}}", noMatch},
+		{"f(){ return {", "{{", "f(){ return {", paMatch},
+		{"{a} {{b", "{{{", "{a} {{b", noMatch},
 	} {
 		var exp = struct {
 			byt []byte
@@ -54,6 +56,7 @@ func Test_rdelim(t *testing.T) {
 		{"c}</h1>", "}}", "c}</h1>", noMatch},
 		{"c}</h1>", "}}}", "c}</h1>", noMatch},
 		{"c}}</h1>", "}}}", "c}}</h1>", noMatch},
+		{"a } b }", "}}", "a } b }", paMatch},
 	} {
 		var exp = struct {
 			byt []byte
@@ -1,5 +1,12 @@
 package beard
 
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sync"
+)
+
 var escapeList = map[byte][]byte{
 	// NOTE from https://golang.org/src/html/escape.go#L189
 	'&':  []byte("&amp;"),
@@ -13,50 +20,199 @@ var escapeList = map[byte][]byte{
 	'}': []byte("&#125;"),
 }
 
-func escapeBytes(b []byte) []byte {
-	lenb := len(b)
-	i := 0
-	for ; i < lenb; i++ {
-		char := b[i]
-		esc, ok := escapeList[char]
-		if !ok {
+// htmlEscapes indexes the escapes of escapeList by byte, so each byte is
+// looked up without hashing
+var htmlEscapes = func() (t [256][]byte) {
+	for c, esc := range escapeList {
+		t[c] = esc
+	}
+
+	return
+}()
+
+// escapeHTML appends the HTML escaped src to dst in a single pass. Runs of
+// bytes which do not need escaping are appended as a whole and src is never
+// modified.
+func escapeHTML(dst, src []byte) []byte {
+	last := 0
+	for i, c := range src {
+		esc := htmlEscapes[c]
+		if esc == nil {
 			continue
 		}
 
-		lenesc := len(esc)
+		dst = append(dst, src[last:i]...)
+		dst = append(dst, esc...)
+		last = i + 1
+	}
+
+	return append(dst, src[last:]...)
+}
+
+// escapePool holds the buffers templates escape values into
+var escapePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 64)
+
+		return &b
+	},
+}
+
+// maxPooledEscapeBuf is the largest buffer returned to escapePool, so a single
+// large value does not keep its buffer around
+const maxPooledEscapeBuf = 64 << 10
+
+func putEscapeBuf(b *[]byte) {
+	if cap(*b) > maxPooledEscapeBuf {
+		return
+	}
+
+	escapePool.Put(b)
+}
+
+// Escaper escapes the values of variables written by a template
+type Escaper interface {
+	// Escape appends the escaped src to dst, returning the extended dst
+	Escape(dst, src []byte) []byte
+}
+
+// EscaperFunc allows a func to be used as an Escaper
+type EscaperFunc func(dst, src []byte) []byte
+
+// Escape calls fn(dst, src)
+func (fn EscaperFunc) Escape(dst, src []byte) []byte {
+	return fn(dst, src)
+}
+
+var (
+	// NoEscape writes values as is, eg. for plain text
+	NoEscape Escaper = EscaperFunc(escapeNone)
+
+	// HTMLEscape escapes values for HTML, this is the default Escaper. HTML
+	// values are trusted and written as is.
+	HTMLEscape Escaper = htmlEscaper{}
+
+	// JSONEscape escapes values to be written within a JSON string
+	JSONEscape Escaper = EscaperFunc(escapeJSON)
+
+	// CSVEscape writes values as CSV fields, quoting them when needed
+	CSVEscape Escaper = EscaperFunc(escapeCSV)
+
+	// ShellEscape writes values as single quoted shell words
+	ShellEscape Escaper = EscaperFunc(escapeShell)
+
+	// LaTeXEscape escapes the special characters of LaTeX
+	LaTeXEscape Escaper = EscaperFunc(escapeLaTeX)
+)
+
+func escapeNone(dst, src []byte) []byte {
+	return append(dst, src...)
+}
+
+type htmlEscaper struct{}
+
+func (htmlEscaper) Escape(dst, src []byte) []byte {
+	return escapeHTML(dst, src)
+}
+
+// HTML is trusted markup, eg. sanitized user content, which is written without
+// being HTML escaped. html/template.HTML values are trusted as well.
+type HTML string
+
+// trusted returns true if the value of d is trusted HTML
+func trusted(d *Data) bool {
+	if d == nil {
+		return false
+	}
+
+	switch indirect(d.Value).(type) {
+	case HTML, template.HTML:
+		return true
+	}
+
+	return false
+}
+
+func escapeJSON(dst, src []byte) []byte {
+	for _, c := range src {
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
 
-		// we'll normally get a default cap of 8, lets use as much of that
-		// if we can before we make another allocation
-		if capb := cap(b); capb < lenb+lenesc {
-			c := capb * 2
+		default:
+			if c < 0x20 {
+				dst = append(dst, fmt.Sprintf(`\u%04x`, c)...)
 
-			// check to see if the 2x cap is greater than the cap plus 2x the
-			// longest escape, most escapes will come in pairs
-			if min := 2*6 + capb; c < min {
-				c = min
+				continue
 			}
 
-			tmp := make([]byte, 0, c)
-			tmp = append(tmp, b...)
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+func escapeCSV(dst, src []byte) []byte {
+	quote := len(src) > 0 && (src[0] == ' ' || src[0] == '\t') ||
+		bytes.ContainsAny(src, ",\"\r\n")
+	if !quote {
+		return append(dst, src...)
+	}
 
-			b = tmp
+	dst = append(dst, '"')
+	for _, c := range src {
+		if c == '"' {
+			dst = append(dst, '"')
 		}
+		dst = append(dst, c)
+	}
 
-		// replace the char with the first part of the escaped
-		b[i] = esc[0]
+	return append(dst, '"')
+}
 
-		// grow, copy and insert
-		for j := 1; j < lenesc; j++ {
-			b = b[:len(b)+1]
+func escapeShell(dst, src []byte) []byte {
+	dst = append(dst, '\'')
+	for _, c := range src {
+		if c == '\'' {
+			dst = append(dst, `'\''`...)
 
-			copy(b[i+j+1:], b[i+j:])
-			b[i+j] = esc[j]
+			continue
 		}
+		dst = append(dst, c)
+	}
 
-		// recalculate to keep looping properly
-		lenb = len(b)
-		i = i + lenesc - 1
+	return append(dst, '\'')
+}
+
+var latexEscapes = map[byte]string{
+	'\\': `\textbackslash{}`,
+	'{':  `\{`,
+	'}':  `\}`,
+	'$':  `\$`,
+	'&':  `\&`,
+	'#':  `\#`,
+	'^':  `\textasciicircum{}`,
+	'_':  `\_`,
+	'%':  `\%`,
+	'~':  `\textasciitilde{}`,
+}
+
+func escapeLaTeX(dst, src []byte) []byte {
+	for _, c := range src {
+		if esc, ok := latexEscapes[c]; ok {
+			dst = append(dst, esc...)
+
+			continue
+		}
+		dst = append(dst, c)
 	}
 
-	return b
+	return dst
 }
@@ -0,0 +1,667 @@
+package beard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// escState is the state of the HTML written by a template
+type escState uint8
+
+const (
+	stateText escState = iota
+	stateTagOpen
+	stateTagName
+	stateTag // within a tag, between attributes
+	stateAttrName
+	stateAfterName
+	stateBeforeValue
+	stateAttr // within an attribute's value
+	stateMarkup
+	stateComment
+	stateScript // within a <script> element
+	stateStyle  // within a <style> element
+)
+
+// attrType is the type of content an attribute's value holds
+type attrType uint8
+
+const (
+	attrNone attrType = iota
+	attrURL
+	attrJS
+	attrCSS
+)
+
+// urlPart is the part of a URL being written
+type urlPart uint8
+
+const (
+	urlStart urlPart = iota
+	urlPath
+	urlQuery
+)
+
+// jsState marks JS comments, strings are tracked by their quote
+type jsState uint8
+
+const (
+	jsCode jsState = iota
+	jsLineComment
+	jsBlockComment
+)
+
+// escContext tracks the context of the HTML written by a template, so each
+// value can be escaped for where it is written, eg. within an attribute, a URL
+// or a <script>. The context is advanced a byte at a time, so the literal text
+// of a template can be written in chunks.
+type escContext struct {
+	state escState
+
+	// tag is the name of the tag being read and closing marks it as an end tag
+	tag     []byte
+	closing bool
+
+	attrName []byte
+	attr     attrType
+
+	// delim is the quote of an attribute's value, 0 when unquoted
+	delim byte
+
+	url urlPart
+	js  jsState
+
+	// quote is the quote of the JS or CSS string being read, 0 outside of a
+	// string
+	quote   byte
+	escaped bool
+	prev    byte
+
+	// dashes counts the dashes of <!-- and -->
+	dashes int
+
+	// end counts the bytes of </script or </style that have been matched
+	end int
+}
+
+// write advances the context over the literal text b
+func (c *escContext) write(b []byte) {
+	for _, ch := range b {
+		c.next(ch)
+	}
+}
+
+func (c *escContext) next(ch byte) {
+	switch c.state {
+	case stateText:
+		if ch == '<' {
+			c.state = stateTagOpen
+		}
+
+	case stateTagOpen:
+		switch {
+		case isLetter(ch):
+			c.state = stateTagName
+			c.closing = false
+			c.tag = append(c.tag[:0], toLower(ch))
+		case ch == '/':
+			c.state = stateTagName
+			c.closing = true
+			c.tag = c.tag[:0]
+		case ch == '!':
+			c.state = stateMarkup
+			c.dashes = 0
+		case ch != '<':
+			c.state = stateText
+		}
+
+	case stateMarkup:
+		// <!-- opens a comment, anything else is read through to >
+		switch {
+		case ch == '>':
+			c.state = stateText
+		case ch == '-' && c.dashes != -1:
+			if c.dashes++; c.dashes == 2 {
+				c.state = stateComment
+				c.dashes = 0
+			}
+
+		default:
+			c.dashes = -1
+		}
+
+	case stateComment:
+		switch {
+		case ch == '>' && c.dashes >= 2:
+			c.state = stateText
+		case ch == '-':
+			c.dashes++
+
+		default:
+			c.dashes = 0
+		}
+
+	case stateTagName:
+		switch {
+		case isSpace(ch) || ch == '/':
+			c.state = stateTag
+		case ch == '>':
+			c.endTag()
+
+		default:
+			c.tag = append(c.tag, toLower(ch))
+		}
+
+	case stateTag:
+		switch {
+		case isSpace(ch) || ch == '/':
+		case ch == '>':
+			c.endTag()
+
+		default:
+			c.state = stateAttrName
+			c.attrName = append(c.attrName[:0], toLower(ch))
+		}
+
+	case stateAttrName:
+		switch {
+		case ch == '=':
+			c.beforeValue()
+		case isSpace(ch):
+			c.state = stateAfterName
+		case ch == '/':
+			c.state = stateTag
+		case ch == '>':
+			c.endTag()
+
+		default:
+			c.attrName = append(c.attrName, toLower(ch))
+		}
+
+	case stateAfterName:
+		switch {
+		case isSpace(ch):
+		case ch == '=':
+			c.beforeValue()
+		case ch == '/':
+			c.state = stateTag
+		case ch == '>':
+			c.endTag()
+
+		default:
+			c.state = stateAttrName
+			c.attrName = append(c.attrName[:0], toLower(ch))
+		}
+
+	case stateBeforeValue:
+		switch {
+		case isSpace(ch):
+		case ch == '"' || ch == '\'':
+			c.state = stateAttr
+			c.delim = ch
+		case ch == '>':
+			c.endTag()
+
+		default:
+			c.state = stateAttr
+			c.delim = 0
+			c.value(ch)
+		}
+
+	case stateAttr:
+		switch {
+		case c.delim != 0 && ch == c.delim, c.delim == 0 && isSpace(ch):
+			c.state = stateTag
+		case c.delim == 0 && ch == '>':
+			c.endTag()
+
+		default:
+			c.value(ch)
+		}
+
+	case stateScript:
+		if !c.endElement(ch, "</script") {
+			c.nextJS(ch)
+		}
+
+	case stateStyle:
+		if !c.endElement(ch, "</style") {
+			c.nextCSS(ch)
+		}
+	}
+}
+
+// beforeValue starts the value of the current attribute
+func (c *escContext) beforeValue() {
+	c.state = stateBeforeValue
+	c.attr = attrTypeOf(string(c.attrName))
+	c.resetCode()
+}
+
+// value advances the context over a byte of an attribute's value
+func (c *escContext) value(ch byte) {
+	switch c.attr {
+	case attrURL:
+		switch {
+		case ch == '?' || ch == '#':
+			c.url = urlQuery
+		case c.url == urlStart:
+			c.url = urlPath
+		}
+
+	case attrJS:
+		c.nextJS(ch)
+
+	case attrCSS:
+		c.nextCSS(ch)
+	}
+}
+
+// endTag closes the current tag, the contents of <script> and <style> are
+// read as JS and CSS
+func (c *escContext) endTag() {
+	c.state = stateText
+	c.end = 0
+	if c.closing {
+		return
+	}
+
+	switch string(c.tag) {
+	case "script":
+		c.state = stateScript
+	case "style":
+		c.state = stateStyle
+	}
+	c.resetCode()
+}
+
+// endElement matches the end tag of a <script> or <style> element, it returns
+// true once the end tag has been matched.
+func (c *escContext) endElement(ch byte, end string) bool {
+	if toLower(ch) != end[c.end] {
+		c.end = 0
+		if ch == '<' {
+			c.end = 1
+		}
+
+		return false
+	}
+	if c.end++; c.end < len(end) {
+		return false
+	}
+
+	c.state = stateTagName
+	c.closing = true
+	c.tag = append(c.tag[:0], end[2:]...)
+	c.end = 0
+
+	return true
+}
+
+func (c *escContext) resetCode() {
+	c.url = urlStart
+	c.js = jsCode
+	c.quote = 0
+	c.escaped = false
+	c.prev = 0
+}
+
+func (c *escContext) nextJS(ch byte) {
+	switch {
+	case c.js == jsLineComment:
+		if ch == '\n' {
+			c.js = jsCode
+		}
+	case c.js == jsBlockComment:
+		if ch == '/' && c.prev == '*' {
+			c.js = jsCode
+			ch = 0
+		}
+	case c.quote != 0:
+		c.nextString(ch)
+	case ch == '"' || ch == '\'' || ch == '`':
+		c.quote = ch
+	case ch == '/' && c.prev == '/':
+		c.js = jsLineComment
+	case ch == '*' && c.prev == '/':
+		c.js = jsBlockComment
+		ch = 0
+	}
+
+	c.prev = ch
+}
+
+func (c *escContext) nextCSS(ch byte) {
+	switch {
+	case c.quote != 0:
+		c.nextString(ch)
+	case ch == '"' || ch == '\'':
+		c.quote = ch
+	}
+}
+
+func (c *escContext) nextString(ch byte) {
+	switch {
+	case c.escaped:
+		c.escaped = false
+	case ch == '\\':
+		c.escaped = true
+	case ch == c.quote:
+		c.quote = 0
+	}
+}
+
+// escape escapes the value of d for the current context
+func (c *escContext) escape(d *Data) []byte {
+	// trusted HTML is only written as is within text, the markup is written
+	// to the context as the template's own would be
+	if c.state == stateText && trusted(d) {
+		b := d.Bytes()
+		c.write(b)
+
+		return b
+	}
+
+	switch c.state {
+	case stateTagOpen, stateTagName, stateTag, stateAttrName, stateAfterName:
+		return filterName(dataBytes(d))
+
+	case stateBeforeValue:
+		// a value following = starts an unquoted attribute value
+		c.state = stateAttr
+		c.delim = 0
+
+		fallthrough
+
+	case stateAttr:
+		var b []byte
+		switch c.attr {
+		case attrURL:
+			b = c.escapeURL(dataBytes(d))
+		case attrJS:
+			b = c.escapeJS(d)
+		case attrCSS:
+			b = c.escapeCSS(dataBytes(d))
+
+		default:
+			b = dataBytes(d)
+		}
+
+		return escapeAttr(b, c.delim)
+
+	case stateScript:
+		return c.escapeJS(d)
+
+	case stateStyle:
+		return c.escapeCSS(dataBytes(d))
+	}
+
+	return escapeAttr(dataBytes(d), '"')
+}
+
+func (c *escContext) escapeURL(b []byte) []byte {
+	switch c.url {
+	case urlStart:
+		if len(b) == 0 {
+			return b
+		}
+		c.url = urlPath
+		if !safeURL(b) {
+			return []byte(filteredURL)
+		}
+
+		return escapeURL(b, true)
+
+	case urlPath:
+		return escapeURL(b, true)
+	}
+
+	return escapeURL(b, false)
+}
+
+func (c *escContext) escapeJS(d *Data) []byte {
+	switch {
+	case c.js != jsCode:
+		// values within comments are dropped
+		return []byte{}
+	case c.quote != 0:
+		return escapeJSString(dataBytes(d))
+	}
+
+	return jsValue(d)
+}
+
+func (c *escContext) escapeCSS(b []byte) []byte {
+	if c.quote != 0 {
+		return escapeCSSString(b)
+	}
+
+	return filterCSS(b)
+}
+
+const (
+	// filteredValue replaces values that are unsafe for their context
+	filteredValue = "ZbeardZ"
+	filteredURL   = "#" + filteredValue
+)
+
+// urlAttrs are the attributes whose values are URLs
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"codebase":   true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"src":        true,
+	"srcset":     true,
+	"usemap":     true,
+	"xmlns":      true,
+}
+
+func attrTypeOf(name string) attrType {
+	switch {
+	case len(name) > 2 && name[:2] == "on":
+		return attrJS
+	case name == "style":
+		return attrCSS
+	case urlAttrs[name],
+		bytes.Contains([]byte(name), []byte("url")),
+		bytes.Contains([]byte(name), []byte("uri")):
+		return attrURL
+	}
+
+	return attrNone
+}
+
+func dataBytes(d *Data) []byte {
+	if d == nil {
+		return nil
+	}
+
+	return d.Bytes()
+}
+
+// replaceBytes returns b with the bytes fn returns a replacement for replaced.
+// b is returned as is when nothing is replaced.
+func replaceBytes(b []byte, fn func(byte) string) []byte {
+	var out []byte
+	for i, ch := range b {
+		r := fn(ch)
+		if r == "" {
+			if out != nil {
+				out = append(out, ch)
+			}
+
+			continue
+		}
+		if out == nil {
+			out = make([]byte, i, len(b)+len(r))
+			copy(out, b[:i])
+		}
+		out = append(out, r...)
+	}
+	if out == nil {
+		return b
+	}
+
+	return out
+}
+
+// escapeAttr escapes an attribute's value, unquoted values also escape the
+// bytes that would end the value.
+func escapeAttr(b []byte, delim byte) []byte {
+	return replaceBytes(b, func(ch byte) string {
+		if esc, ok := escapeList[ch]; ok {
+			return string(esc)
+		}
+		if delim == 0 && (isSpace(ch) || ch == '=' || ch == '`') {
+			return fmt.Sprintf("&#%d;", ch)
+		}
+
+		return ""
+	})
+}
+
+// filterName filters values written as tag or attribute names
+func filterName(b []byte) []byte {
+	for _, ch := range b {
+		if !isLetter(ch) && !isDigit(ch) && ch != '-' && ch != '_' && ch != ':' {
+			return []byte(filteredValue)
+		}
+	}
+
+	return b
+}
+
+// safeURLSchemes are the schemes allowed at the start of a URL, URLs without
+// a scheme are relative and are always allowed.
+var safeURLSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+func safeURL(b []byte) bool {
+	i := bytes.IndexAny(b, ":/?#")
+	if i == -1 || b[i] != ':' {
+		return true
+	}
+
+	return safeURLSchemes[string(bytes.ToLower(bytes.TrimSpace(b[:i])))]
+}
+
+// escapeURL percent encodes the bytes of b that are not allowed in a URL. When
+// normalizing, the bytes that have a meaning within a URL are kept, otherwise
+// b is encoded as a query value.
+func escapeURL(b []byte, norm bool) []byte {
+	return replaceBytes(b, func(ch byte) string {
+		switch {
+		case isLetter(ch) || isDigit(ch):
+			return ""
+		}
+
+		switch ch {
+		case '-', '.', '_', '~':
+			return ""
+		case '!', '#', '$', '&', '*', '+', ',', '/', ':', ';', '=', '?', '@',
+			'[', ']', '%':
+			if norm {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("%%%02X", ch)
+	})
+}
+
+// jsValue writes the value of d as a JS value
+func jsValue(d *Data) []byte {
+	var v interface{}
+	if d != nil {
+		v = indirect(d.Value)
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(sprint(v))
+	}
+
+	// pad the value so it can not join with the JS around it, eg. into a
+	// comment
+	return append(append([]byte(" "), b...), ' ')
+}
+
+// escapeJSString escapes b to be written within a JS string
+func escapeJSString(b []byte) []byte {
+	return replaceBytes(b, func(ch byte) string {
+		switch ch {
+		case '\\':
+			return `\\`
+		case '"', '\'', '`', '<', '>', '&', '$', '=', '+', '/':
+			return fmt.Sprintf(`\u%04x`, ch)
+		}
+		if ch < 0x20 || ch == 0x7f {
+			return fmt.Sprintf(`\u%04x`, ch)
+		}
+
+		return ""
+	})
+}
+
+// escapeCSSString escapes b to be written within a CSS string
+func escapeCSSString(b []byte) []byte {
+	return replaceBytes(b, func(ch byte) string {
+		if isLetter(ch) || isDigit(ch) || ch == ' ' || ch >= 0x80 {
+			return ""
+		}
+
+		// hex escapes are ended by a space, which is consumed
+		return fmt.Sprintf(`\%x `, ch)
+	})
+}
+
+// filterCSS filters values written as CSS, only simple values, eg. colors,
+// sizes and names, are allowed.
+func filterCSS(b []byte) []byte {
+	for _, ch := range b {
+		switch {
+		case isLetter(ch) || isDigit(ch):
+		case ch == ' ', ch == '#', ch == '%', ch == ',', ch == '.', ch == '-',
+			ch == '_', ch == '!':
+
+		default:
+			return []byte(filteredValue)
+		}
+	}
+	if bytes.Contains(bytes.ToLower(b), []byte("expression")) {
+		return []byte(filteredValue)
+	}
+
+	return b
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
@@ -0,0 +1,148 @@
+package beard
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestTemplateContextualEscape(t *testing.T) {
+	data := map[string]interface{}{
+		"text":  `<b>"a" & 'b'</b>`,
+		"url":   "javascript:alert(1)",
+		"link":  "https://example.com/a b?c=d",
+		"query": "a b&c=d/e",
+		"name":  `O'Reilly </script>`,
+		"n":     42,
+		"list":  []string{"a", "b"},
+		"color": "red",
+		"bad":   "red;background:url(x)",
+		"attr":  "onclick=alert(1)",
+		"space": "a onclick=alert(1)",
+		"path":  "/a/b c",
+		"raw":   "<a href='",
+		"open":  "<script>",
+	}
+
+	for _, v := range []struct {
+		giv, exp string
+	}{
+		{`<p>{{text}}</p>`, `<p>&lt;b&gt;&#34;a&#34; &amp; &#39;b&#39;&lt;/b&gt;</p>`},
+		{`<a href="{{url}}">`, `<a href="#ZbeardZ">`},
+		{`<a href='{{link}}'>`, `<a href='https://example.com/a%20b?c=d'>`},
+		{`<a href="/search?q={{query}}">`, `<a href="/search?q=a%20b%26c%3Dd%2Fe">`},
+		{`<a href="{{path}}?q={{query}}">`, `<a href="/a/b%20c?q=a%20b%26c%3Dd%2Fe">`},
+		{`<img src={{space}}>`, `<img src=a%20onclick&#61;alert%281%29>`},
+		{`<input value={{space}}>`, `<input value=a&#32;onclick&#61;alert(1)>`},
+		{`<div {{attr}}>`, `<div ZbeardZ>`},
+		{`<script>var a = {{name}}, n = {{n}}, l = {{list}};</script>`,
+			`<script>var a =  "O'Reilly \u003c/script\u003e" , n =  42 , l =  ["a","b"] ;</script>`},
+		{`<script>var a = "{{name}}", b = '{{name}}';</script>`,
+			`<script>var a = "O\u0027Reilly \u003c\u002fscript\u003e", b = 'O\u0027Reilly \u003c\u002fscript\u003e';</script>`},
+		{`<script>// {{name}}` + "\n" + `</script><p>{{text}}</p>`,
+			`<script>// ` + "\n" + `</script><p>&lt;b&gt;&#34;a&#34; &amp; &#39;b&#39;&lt;/b&gt;</p>`},
+		{`<button onclick="say('{{name}}')">`,
+			`<button onclick="say('O\u0027Reilly \u003c\u002fscript\u003e')">`},
+		{`<button onclick="say({{name}})">`,
+			`<button onclick="say( &#34;O&#39;Reilly \u003c/script\u003e&#34; )">`},
+		{`<style>p { color: {{color}}; } a { color: {{bad}}; }</style>`,
+			`<style>p { color: red; } a { color: ZbeardZ; }</style>`},
+		{`<p style="color: {{color}}; font: '{{name}}'">`,
+			`<p style="color: red; font: 'O\27 Reilly \3c \2f script\3e '">`},
+		{`<!-- {{text}} --><p title="{{text}}">`,
+			`<!-- &lt;b&gt;&#34;a&#34; &amp; &#39;b&#39;&lt;/b&gt; --><p title="&lt;b&gt;&#34;a&#34; &amp; &#39;b&#39;&lt;/b&gt;">`},
+		{`<SCRIPT>{{n}}</Script ><a href="{{url}}">`,
+			`<SCRIPT> 42 </Script ><a href="#ZbeardZ">`},
+		{`<p>{{&text}}</p>`, `<p><b>"a" & 'b'</b></p>`},
+		{`{{&raw}}{{url}}'>`, `<a href='#ZbeardZ'>`},
+		{`{{&open}}var a = {{name}};</script>`,
+			`<script>var a =  "O'Reilly \u003c/script\u003e" ;</script>`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.giv)),
+			Data: &Data{Value: data},
+		}
+		tmpl.ContextualEscape()
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateContextualEscapePartials(t *testing.T) {
+	html := `<a href="{{>href}}">{{#items}}{{>item}}{{/items}}</a>`
+	data := map[string]interface{}{
+		"url":   "javascript:alert(1)",
+		"items": []string{"<b>", "c"},
+	}
+
+	var exp = `<a href="#ZbeardZ"><script>var i = "\u003cb\u003e";</script><script>var i = "c";</script></a>`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+	tmpl.ContextualEscape()
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		var p []byte
+		switch path {
+		case "href":
+			p = []byte(`{{url}}`)
+		case "item":
+			p = []byte(`<script>var i = "{{.}}";</script>`)
+
+		default:
+			t.Errorf("invalid partial %s", path)
+		}
+
+		return bytes.NewReader(p), nil
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func Test_escContextSplitWrites(t *testing.T) {
+	src := []byte(`<p class="a"><script type="text/javascript">var s = "</p>"; // '` +
+		"\n" + `</script ><a onclick='f("x")' href=`)
+
+	for n := 1; n < len(src); n++ {
+		c := &escContext{}
+		for i := 0; i < len(src); i += n {
+			j := i + n
+			if j > len(src) {
+				j = len(src)
+			}
+			c.write(src[i:j])
+		}
+
+		if c.state != stateBeforeValue || c.attr != attrURL {
+			t.Errorf("expected to be before a URL value writing by %d, got %d %d",
+				n, c.state, c.attr)
+		}
+	}
+}
+
+func TestRenderInLayoutContextualEscape(t *testing.T) {
+	tmpl := RenderInLayout(
+		bytes.NewReader([]byte(`<head>{{>yield scripts}}</head><body>{{>yield}}</body>`)),
+		bytes.NewReader([]byte(`{{#content_for scripts}}<script>var a = {{a}};</script>{{/content_for}}<a href="{{a}}">{{a}}</a>`)),
+		map[string]interface{}{
+			"a": "javascript:<x>",
+		},
+		nil,
+	).(*Template)
+	tmpl.ContextualEscape()
+
+	var exp = `<head><script>var a =  "javascript:\u003cx\u003e" ;</script></head>` +
+		`<body><a href="#ZbeardZ">javascript:&lt;x&gt;</a></body>`
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
@@ -1,10 +1,11 @@
 package beard
 
 import (
+	"bytes"
 	"testing"
 )
 
-func Test_escapeBytes(t *testing.T) {
+func Test_escapeHTML(t *testing.T) {
 	for _, v := range []struct {
 		giv string
 		exp string
@@ -12,10 +13,111 @@ func Test_escapeBytes(t *testing.T) {
 		{"<h1>", "&lt;h1&gt;"},
 		{"</h1>", "&lt;/h1&gt;"},
 		{"<h1>{{c}}</h1>", "&lt;h1&gt;&#123;&#123;c&#125;&#125;&lt;/h1&gt;"},
+		{"plain", "plain"},
+		{"", ""},
 	} {
-		b := escapeBytes([]byte(v.giv))
+		b := escapeHTML(nil, []byte(v.giv))
 		if got := string(b); v.exp != got {
 			t.Errorf("expected %s, got %s", v.exp, got)
 		}
 	}
 }
+
+func Test_escapeHTMLDoesNotModifySrc(t *testing.T) {
+	src := make([]byte, 3, 64)
+	copy(src, "<a>")
+
+	b := escapeHTML(nil, src)
+	if got := string(b); got != "&lt;a&gt;" {
+		t.Errorf("expected &lt;a&gt;, got %s", got)
+	}
+	if got := string(src[:cap(src)][:3]); got != "<a>" {
+		t.Errorf("expected src to be unmodified, got %s", got)
+	}
+	if got := string(src[:cap(src)][3:6]); got != "\x00\x00\x00" {
+		t.Errorf("expected src's capacity to be unused, got %q", got)
+	}
+}
+
+func TestTemplateDoesNotModifyByteValues(t *testing.T) {
+	val := make([]byte, 3, 64)
+	copy(val, "<a>")
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(`{{a}}{{a}}`)),
+		Data: &Data{Value: map[string]interface{}{"a": val}},
+	}
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals("&lt;a&gt;&lt;a&gt;")).
+		And(errorIs(nil))
+
+	if got := string(val); got != "<a>" {
+		t.Errorf("expected value to be unmodified, got %s", got)
+	}
+}
+
+func benchmarkEscapeHTML(b *testing.B, n int) {
+	src := bytes.Repeat([]byte(`<"&'>`), n/5)
+	dst := make([]byte, 0, len(src)*6)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dst = escapeHTML(dst[:0], src)
+	}
+}
+
+func BenchmarkEscapeHTML1K(b *testing.B)  { benchmarkEscapeHTML(b, 1<<10) }
+func BenchmarkEscapeHTML64K(b *testing.B) { benchmarkEscapeHTML(b, 64<<10) }
+func BenchmarkEscapeHTML1M(b *testing.B)  { benchmarkEscapeHTML(b, 1<<20) }
+
+func BenchmarkTemplateEscape(b *testing.B) {
+	data := map[string]interface{}{
+		"a": bytes.Repeat([]byte(`<p class="a">'b' & c</p>`), 1<<10),
+	}
+	src := []byte(`<div>{{a}}</div>`)
+	p := make([]byte, 4096)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tmpl := &Template{
+			File: bytes.NewReader(src),
+			Data: &Data{Value: data},
+		}
+		for {
+			if _, err := tmpl.Read(p); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func TestEscapers(t *testing.T) {
+	for _, v := range []struct {
+		esc      Escaper
+		giv, exp string
+	}{
+		{NoEscape, `<a href="x">&</a>`, `<a href="x">&</a>`},
+		{HTMLEscape, `<a href="x">&</a>`, `&lt;a href=&#34;x&#34;&gt;&amp;&lt;/a&gt;`},
+		{JSONEscape, "\"a\\b\"\n\t\x01", `\"a\\b\"\n\t\u0001`},
+		{CSVEscape, `a b`, `a b`},
+		{CSVEscape, ` a`, `" a"`},
+		{CSVEscape, `a,"b"`, `"a,""b"""`},
+		{CSVEscape, "a\nb", "\"a\nb\""},
+		{ShellEscape, `it's $HOME`, `'it'\''s $HOME'`},
+		{ShellEscape, ``, `''`},
+		{LaTeXEscape, `50% of $5 & #1_a {b} ~ ^ \`,
+			`50\% of \$5 \& \#1\_a \{b\} \textasciitilde{} \textasciicircum{} \textbackslash{}`},
+	} {
+		b := v.esc.Escape([]byte("> "), []byte(v.giv))
+		if got := string(b); "> "+v.exp != got {
+			t.Errorf("expected %s, got %s", "> "+v.exp, got)
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package beard
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// SyntaxError is returned when a tag can not be parsed. Offset is the byte
+// offset within the template where the error occurred.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokPath
+	tokString
+	tokNumber
+	tokBool
+	tokNil
+	tokOp
+	tokNot
+	tokLparen
+	tokRparen
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+// lexExpr splits an expression into tokens. pos is added to the position of
+// each token so errors can be reported relative to the template.
+func lexExpr(b []byte, pos int) ([]token, error) {
+	var toks []token
+
+	lenb := len(b)
+	i := 0
+	for i < lenb {
+		c := b[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(':
+			toks = append(toks, token{tokLparen, "(", pos + i})
+			i++
+
+		case c == ')':
+			toks = append(toks, token{tokRparen, ")", pos + i})
+			i++
+
+		case c == '"' || c == '\'':
+			s, n, err := lexString(b[i:])
+			if err != nil {
+				return nil, &SyntaxError{pos + i, err.Error()}
+			}
+			toks = append(toks, token{tokString, s, pos + i})
+			i += n
+
+		case isDigit(c) || (c == '-' && i+1 < lenb && isDigit(b[i+1])):
+			j := i + 1
+			for j < lenb && (isDigit(b[j]) || b[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, string(b[i:j]), pos + i})
+			i = j
+
+		case isPathChar(c):
+			j := i + 1
+			for j < lenb && (isPathChar(b[j]) || b[j] == '-') {
+				j++
+			}
+			tok := token{tokPath, string(b[i:j]), pos + i}
+			switch tok.val {
+			case "true", "false":
+				tok.typ = tokBool
+			case "nil", "null":
+				tok.typ = tokNil
+			}
+			toks = append(toks, tok)
+			i = j
+
+		default:
+			op := lexOp(b[i:])
+			if op == "" {
+				return nil, &SyntaxError{pos + i,
+					fmt.Sprintf("unexpected character %q", c)}
+			}
+			typ := tokOp
+			if op == "!" {
+				typ = tokNot
+			}
+			toks = append(toks, token{typ, op, pos + i})
+			i += len(op)
+		}
+	}
+
+	return append(toks, token{tokEOF, "", pos + lenb}), nil
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "="}
+
+func lexOp(b []byte) string {
+	for _, op := range operators {
+		if bytes.HasPrefix(b, []byte(op)) {
+			return op
+		}
+	}
+
+	return ""
+}
+
+// lexString reads a quoted string, returning the unquoted value and the number
+// of bytes consumed.
+func lexString(b []byte) (string, int, error) {
+	q := b[0]
+
+	var s []byte
+	i := 1
+	for ; i < len(b); i++ {
+		c := b[i]
+		if c == '\\' && i+1 < len(b) {
+			i++
+			s = append(s, b[i])
+
+			continue
+		}
+		if c == q {
+			return string(s), i + 1, nil
+		}
+
+		s = append(s, c)
+	}
+
+	return "", i, fmt.Errorf("unterminated string")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isPathChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) ||
+		c == '_' || c == '.' || c == '/' || c == '@'
+}
+
+// expr is a node of a parsed condition
+type expr interface {
+	eval(t *Template) (interface{}, error)
+}
+
+type (
+	literalExpr struct {
+		val interface{}
+	}
+
+	pathExpr struct {
+		path string
+	}
+
+	notExpr struct {
+		x expr
+	}
+
+	binaryExpr struct {
+		op   string
+		x, y expr
+	}
+)
+
+func (e literalExpr) eval(t *Template) (interface{}, error) {
+	return e.val, nil
+}
+
+// eval looks up the path the same way variables do, calling a Lambda for its
+// value
+func (e pathExpr) eval(t *Template) (interface{}, error) {
+	d, err := t.call(t.lookup(e.path))
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	return d.Value, nil
+}
+
+func (e notExpr) eval(t *Template) (interface{}, error) {
+	ok, err := evalTruthy(e.x, t)
+
+	return !ok, err
+}
+
+func (e binaryExpr) eval(t *Template) (interface{}, error) {
+	switch e.op {
+	case "&&", "||":
+		ok, err := evalTruthy(e.x, t)
+		if err != nil || ok == (e.op == "||") {
+			return ok, err
+		}
+
+		return evalTruthy(e.y, t)
+	}
+
+	x, err := e.x.eval(t)
+	if err != nil {
+		return nil, err
+	}
+	y, err := e.y.eval(t)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	}
+
+	n, ok := compare(x, y)
+	if !ok {
+		return false, nil
+	}
+
+	switch e.op {
+	case "<":
+		return n < 0, nil
+	case "<=":
+		return n <= 0, nil
+	case ">":
+		return n > 0, nil
+	case ">=":
+		return n >= 0, nil
+	}
+
+	return false, nil
+}
+
+// evalTruthy evaluates x as a condition
+func evalTruthy(x expr, t *Template) (bool, error) {
+	v, err := x.eval(t)
+	if err != nil {
+		return false, err
+	}
+
+	return truthy(v), nil
+}
+
+// parseExpr parses a condition, eg. status == "active" && count > 0. pos is the
+// offset of the condition within the template.
+func parseExpr(b []byte, pos int) (expr, error) {
+	toks, err := lexExpr(b, pos)
+	if err != nil {
+		return nil, err
+	}
+	if toks[0].typ == tokEOF {
+		return nil, &SyntaxError{pos, "missing condition"}
+	}
+
+	p := &exprParser{toks: toks}
+
+	x, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != tokEOF {
+		return nil, &SyntaxError{tok.pos,
+			fmt.Sprintf("unexpected %q", tok.val)}
+	}
+
+	return x, nil
+}
+
+type exprParser struct {
+	toks []token
+	i    int
+}
+
+func (p *exprParser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *exprParser) next() token {
+	tok := p.toks[p.i]
+	if tok.typ != tokEOF {
+		p.i++
+	}
+
+	return tok
+}
+
+func (p *exprParser) or() (expr, error) {
+	x, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().val == "||" {
+		p.next()
+
+		y, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{"||", x, y}
+	}
+
+	return x, nil
+}
+
+func (p *exprParser) and() (expr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().val == "&&" {
+		p.next()
+
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{"&&", x, y}
+	}
+
+	return x, nil
+}
+
+func (p *exprParser) unary() (expr, error) {
+	if p.peek().typ == tokNot {
+		p.next()
+
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notExpr{x}, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *exprParser) comparison() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op := p.peek(); op.val {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+
+		y, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+
+		return binaryExpr{op.val, x, y}, nil
+	}
+
+	return x, nil
+}
+
+func (p *exprParser) primary() (expr, error) {
+	tok := p.next()
+
+	switch tok.typ {
+	case tokLparen:
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.typ != tokRparen {
+			return nil, &SyntaxError{end.pos, "expected )"}
+		}
+
+		return x, nil
+
+	case tokString:
+		return literalExpr{tok.val}, nil
+
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, &SyntaxError{tok.pos,
+				fmt.Sprintf("invalid number %q", tok.val)}
+		}
+
+		return literalExpr{f}, nil
+
+	case tokBool:
+		return literalExpr{tok.val == "true"}, nil
+
+	case tokNil:
+		return literalExpr{nil}, nil
+
+	case tokPath:
+		return pathExpr{tok.val}, nil
+
+	case tokEOF:
+		return nil, &SyntaxError{tok.pos, "unexpected end of condition"}
+	}
+
+	return nil, &SyntaxError{tok.pos, fmt.Sprintf("unexpected %q", tok.val)}
+}
+
+// indirect returns the underlying value of reflect.Values and pointers
+func indirect(v interface{}) interface{} {
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		if v == nil {
+			return nil
+		}
+		rv = reflect.ValueOf(v)
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if rv.CanInterface() {
+		return rv.Interface()
+	}
+
+	return rv
+}
+
+// truthy returns the truthiness of a value. nil, false, 0, "" and empty
+// slices and maps are false.
+func truthy(v interface{}) bool {
+	v = indirect(v)
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() > 0
+	}
+
+	return true
+}
+
+// toFloat converts numeric values to a float64
+func toFloat(v interface{}) (float64, bool) {
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
+
+// toString converts string like values to a string
+func toString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	case reflect.Value:
+		if s.Kind() == reflect.String {
+			return s.String(), true
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.String {
+		return rv.String(), true
+	}
+
+	return "", false
+}
+
+func equal(a, b interface{}) bool {
+	a, b = indirect(a), indirect(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if n, ok := compare(a, b); ok {
+		return n == 0
+	}
+	if x, ok := a.(bool); ok {
+		y, ok := b.(bool)
+
+		return ok && x == y
+	}
+
+	return false
+}
+
+// compare compares numbers to numbers and strings to strings. It returns false
+// if the values can not be compared.
+func compare(a, b interface{}) (int, bool) {
+	a, b = indirect(a), indirect(b)
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+
+		return 0, true
+	}
+	if x, ok := toString(a); ok {
+		y, ok := toString(b)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,64 @@
+package beard
+
+import (
+	"testing"
+)
+
+func Test_parseExpr(t *testing.T) {
+	data := map[string]interface{}{
+		"a":     "b",
+		"n":     3,
+		"f":     2.5,
+		"t":     true,
+		"empty": []string{},
+		"list":  []string{"a"},
+		"s": struct {
+			N int64
+		}{N: 3},
+	}
+
+	tmpl := &Template{
+		Data: &Data{Value: data},
+	}
+
+	for _, v := range []struct {
+		giv string
+		exp bool
+	}{
+		{`a`, true},
+		{`!a`, false},
+		{`missing`, false},
+		{`a == "b"`, true},
+		{`a == 'b'`, true},
+		{`a != "b"`, false},
+		{`n == 3`, true},
+		{`n == s.N`, true},
+		{`n > f`, true},
+		{`n <= 2`, false},
+		{`f >= -1`, true},
+		{`a < "c"`, true},
+		{`a > 1`, false},
+		{`t && n`, true},
+		{`empty || list`, true},
+		{`empty`, false},
+		{`!(t && empty)`, true},
+		{`missing == nil`, true},
+		{`t == true`, true},
+	} {
+		x, err := parseExpr([]byte(v.giv), 0)
+		if err != nil {
+			t.Errorf("expected no error for %s, got %s", v.giv, err)
+
+			continue
+		}
+		got, err := evalTruthy(x, tmpl)
+		if err != nil {
+			t.Errorf("expected no error for %s, got %s", v.giv, err)
+
+			continue
+		}
+		if v.exp != got {
+			t.Errorf("expected %s to be %t, got %t", v.giv, v.exp, got)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package beard
+
+import (
+	"bytes"
+	"strings"
+)
+
+// FormatOptions configures Format
+type FormatOptions struct {
+	// Indent indents the content of sections by their depth, eg. two spaces.
+	// Content is not indented when it is empty. Indenting changes the
+	// whitespace of the rendered output.
+	Indent string
+}
+
+// Format formats the template src, normalizing the spacing within its tags,
+// eg. {{ name }} and {{# items as k,v}} are formatted as {{name}} and
+// {{#items as k, v}}. Only the spacing of tags is changed, so the template
+// renders the same output, unless it is indented. An invalid template is not
+// formatted and its *SyntaxError is returned.
+func Format(src []byte, opts FormatOptions) ([]byte, error) {
+	if err := Check(src); err != nil {
+		return nil, err
+	}
+
+	tree := parseTree(src)
+
+	f := &formatter{
+		indent:    opts.Indent,
+		lineStart: true,
+	}
+	f.nodes(tree, 0)
+
+	return f.buf.Bytes(), nil
+}
+
+// node is a node of a template's syntax tree, the text of each node is its
+// source so the tree can be printed without loss
+type node struct {
+	text []byte
+
+	// tag is the contents of a tag, it is nil for text
+	tag []byte
+
+	// children and end are the content and close tag of a section
+	children []*node
+	end      *node
+}
+
+// parseTree parses src into a tree of text, tags and sections. src must have
+// been checked as the sections are expected to be balanced.
+func parseTree(src []byte) []*node {
+	var (
+		root  []*node
+		stack []*node
+	)
+	add := func(n *node) {
+		if z := len(stack) - 1; z > -1 {
+			stack[z].children = append(stack[z].children, n)
+		} else {
+			root = append(root, n)
+		}
+	}
+
+	i := 0
+	for i < len(src) {
+		tag, start, end, ok := nextTag(src, i)
+		if !ok {
+			add(&node{text: src[i:]})
+
+			break
+		}
+		if start > i {
+			add(&node{text: src[i:start]})
+		}
+		i = end
+
+		n := &node{
+			text: src[start:end],
+			tag:  tag,
+		}
+
+		sigil, _ := sectionName(tag)
+		switch sigil {
+		case 0:
+			add(n)
+
+		case '/':
+			z := len(stack) - 1
+			stack[z].end = n
+			stack = stack[:z]
+
+		default:
+			add(n)
+			stack = append(stack, n)
+		}
+	}
+
+	return root
+}
+
+// formatter prints a syntax tree with its tags formatted
+type formatter struct {
+	buf    bytes.Buffer
+	indent string
+
+	// lineStart marks that a line has been started but nothing written to it
+	lineStart bool
+}
+
+func (f *formatter) nodes(nodes []*node, depth int) {
+	for _, n := range nodes {
+		if n.tag == nil {
+			f.text(n.text, depth)
+
+			continue
+		}
+
+		// else outdents to its section, an else outside of one is left as is
+		d := depth
+		if isElseTag(n.tag) && d > 0 {
+			d--
+		}
+		f.tag(n.tag, d)
+
+		if n.end != nil {
+			f.nodes(n.children, depth+1)
+			f.tag(n.end.tag, depth)
+		}
+	}
+}
+
+func (f *formatter) tag(tag []byte, depth int) {
+	f.startLine(depth)
+
+	f.buf.Write(ldelim.Value())
+	f.buf.WriteString(formatTag(tag))
+	f.buf.Write(rdelim.Value())
+}
+
+// startLine indents a started line to depth
+func (f *formatter) startLine(depth int) {
+	if f.lineStart && f.indent != "" {
+		f.buf.WriteString(strings.Repeat(f.indent, depth))
+	}
+	f.lineStart = false
+}
+
+// text writes text, re-indenting each of its lines to depth when indenting
+func (f *formatter) text(b []byte, depth int) {
+	if f.indent == "" {
+		f.buf.Write(b)
+
+		return
+	}
+
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	for i, line := range lines {
+		if f.lineStart {
+			line = bytes.TrimLeft(line, " \t")
+
+			// a line of only whitespace is left for the tag that follows it
+			if i == len(lines)-1 && len(line) == 0 {
+				break
+			}
+			if line[0] != '\n' {
+				f.startLine(depth)
+			}
+		}
+		f.buf.Write(line)
+
+		f.lineStart = line[len(line)-1] == '\n'
+	}
+}
+
+func isElseTag(tag []byte) bool {
+	if kw, _, _, ok := parseCond(tag); ok {
+		return kw == elseIfTag
+	}
+
+	s := string(cleanSpaces(append([]byte(nil), tag...)))
+
+	return s == elseTag || s == elseTagAlt
+}
+
+// formatTag returns tag with its spacing normalized
+func formatTag(tag []byte) string {
+	// tags are parsed in the order they are when rendered, see handleVar
+	if kw, cond, _, ok := parseCond(tag); ok {
+		s := collapseSpaces(cond)
+		if kw == ifTag {
+			return ifTag + " " + s
+		}
+		if bytes.HasPrefix(bytes.TrimLeft(tag, " "), []byte(elseTagAlt)) {
+			return elseTagAlt + " if " + s
+		}
+
+		return elseIfTag + " " + s
+	}
+	if name, ok := parseKeyword(tag, contentForTag); ok {
+		return joinWords(contentForTag, name)
+	}
+	if name, ok := parseKeyword(tag, yieldTag); ok {
+		return joinWords(yieldTag, name)
+	}
+
+	trimmed := bytes.Trim(tag, " ")
+	if len(trimmed) == 0 {
+		return string(tag)
+	}
+
+	switch sigil := trimmed[0]; sigil {
+	case '>':
+		// the name of a partial ends at the first space, its arguments follow
+		rest := bytes.TrimLeft(trimmed[1:], " ")
+
+		name, args := rest, []byte(nil)
+		if i := bytes.IndexByte(rest, ' '); i != -1 {
+			name, args = rest[:i], rest[i:]
+		}
+
+		return joinWords(">"+string(name), collapseSpaces(args))
+
+	case '#', '^', '<', '$':
+		s := collapseSpaces(trimmed[1:])
+
+		// aliases are separated by a comma and a space
+		if i := strings.Index(s, " as "); i != -1 {
+			s = s[:i] + " as " + formatAliases(s[i+4:])
+		}
+
+		return string(sigil) + s
+	}
+
+	// variables have all of their spaces removed when rendered
+	return string(cleanSpaces(append([]byte(nil), tag...)))
+}
+
+// formatAliases formats the aliases at the start of s, which are followed by
+// any modifiers
+func formatAliases(s string) string {
+	words := strings.Split(s, " ")
+
+	n := 0
+	for n < len(words) && !strings.Contains(words[n], ":") {
+		n++
+	}
+
+	as := strings.Split(strings.Join(words[:n], ""), ",")
+
+	return strings.Join(append([]string{strings.Join(as, ", ")}, words[n:]...), " ")
+}
+
+func joinWords(a, b string) string {
+	if b == "" {
+		return a
+	}
+
+	return a + " " + b
+}
+
+// collapseSpaces trims b and collapses its runs of spaces into a single space,
+// spaces within quoted strings are kept
+func collapseSpaces(b []byte) string {
+	var (
+		s     = make([]byte, 0, len(b))
+		quote byte
+	)
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(b) {
+				s = append(s, c)
+				i++
+				c = b[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ':
+			if len(s) == 0 || s[len(s)-1] == ' ' {
+				continue
+			}
+		}
+
+		s = append(s, c)
+	}
+
+	return string(bytes.TrimRight(s, " "))
+}
@@ -0,0 +1,151 @@
+package beard
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// source prints the nodes of a tree as they were parsed
+func source(nodes []*node) string {
+	var b bytes.Buffer
+	for _, n := range nodes {
+		b.Write(n.text)
+		if n.end != nil {
+			b.WriteString(source(n.children))
+			b.Write(n.end.text)
+		}
+	}
+
+	return b.String()
+}
+
+func Test_parseTreeIsLossless(t *testing.T) {
+	for _, v := range []string{
+		``,
+		`text only`,
+		`<h1>{{ title }}</h1>{{# items as k,v}} {{k}}{{else}}none{{/ items }} a }} b`,
+		`{{<layout}}{{$title}}{{#if a > 1}}a{{else if b}}b{{/if}}{{/title}}{{/layout}}`,
+	} {
+		if got := source(parseTree([]byte(v))); got != v {
+			t.Errorf("expected %s, got %s", v, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, v := range []struct {
+		giv, exp string
+	}{
+		{`<h1>{{ title }}</h1>{{ & body }}`, `<h1>{{title}}</h1>{{&body}}`},
+		{`{{# items  as k,v  sort:k  desc }}{{ k }}{{ else }}-{{/ items }}`,
+			`{{#items as k, v sort:k desc}}{{k}}{{else}}-{{/items}}`},
+		{`{{# if a  ==  "x  y" }}a{{ else  if b }}b{{ :else }}c{{/ if }}`,
+			`{{#if a == "x  y"}}a{{else if b}}b{{:else}}c{{/if}}`},
+		{`{{>  card   item  label="a  b" }}{{> header }}{{> yield  title }}`,
+			`{{>card item label="a  b"}}{{>header}}{{>yield title}}`},
+		{`{{#content_for  scripts }}s{{/content_for}}{{< layout }}{{$ title }}t{{/title}}{{/layout}}`,
+			`{{#content_for scripts}}s{{/content_for}}{{<layout}}{{$title}}t{{/title}}{{/layout}}`},
+		{"a }} {{^ empty }}\n\t{{ x }}\n{{/empty}}", "a }} {{^empty}}\n\t{{x}}\n{{/empty}}"},
+	} {
+		got, err := Format([]byte(v.giv), FormatOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != v.exp {
+			t.Errorf("expected %s, got %s", v.exp, got)
+		}
+	}
+}
+
+func TestFormatIndent(t *testing.T) {
+	src := `<ul>
+{{#items}}
+<li>
+{{#if done}}
+<s>{{name}}</s>
+{{else}}
+    {{name}}
+
+{{/if}}
+</li>
+{{/items}}
+</ul>
+`
+
+	var exp = `<ul>
+{{#items}}
+  <li>
+  {{#if done}}
+    <s>{{name}}</s>
+  {{else}}
+    {{name}}
+
+  {{/if}}
+  </li>
+{{/items}}
+</ul>
+`
+
+	got, err := Format([]byte(src), FormatOptions{Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestFormatIndentElseOutsideSection(t *testing.T) {
+	for _, v := range []string{
+		"{{else}}\n",
+		"a\n{{ else }}\nb\n",
+	} {
+		got, err := Format([]byte(v), FormatOptions{Indent: "  "})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := strings.Replace(v, " else ", "else", 1); string(got) != exp {
+			t.Errorf("expected %q, got %q", exp, got)
+		}
+	}
+}
+
+func TestFormatRendersTheSame(t *testing.T) {
+	src := `{{# items  as item  sort:name }}({{ item.name }}{{# if item.n >  1 }}!{{/ if}}){{/ items }}{{ & raw }}`
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "b", "n": 2},
+			map[string]interface{}{"name": "a", "n": 1},
+		},
+		"raw": "<b>",
+	}
+
+	formatted, err := Format([]byte(src), FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out [2]string
+	for i, s := range []string{src, string(formatted)} {
+		b, err := ioutil.ReadAll(Render(bytes.NewReader([]byte(s)), data, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = string(b)
+	}
+	if out[0] != out[1] {
+		t.Errorf("expected %s, got %s", out[0], out[1])
+	}
+	if exp := "(a)(b!)<b>"; out[1] != exp {
+		t.Errorf("expected %s, got %s", exp, out[1])
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	_, err := Format([]byte(`{{#a}}`), FormatOptions{})
+	if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+}
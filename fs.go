@@ -0,0 +1,151 @@
+package beard
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sync"
+	"time"
+)
+
+// FSOptions configures how partials are found within an fs.FS
+type FSOptions struct {
+	// Extensions are tried, in order, after the partial's name as is, eg.
+	// .mustache or .html
+	Extensions []string
+
+	// Paths are the directories searched, in order, for partials. The root of
+	// the fs.FS is searched when no paths are given.
+	Paths []string
+
+	// Reload checks the modification time of a cached partial each time it is
+	// loaded, reading it again when it has changed. This is meant for
+	// development, otherwise partials are cached until the process exits.
+	Reload bool
+}
+
+// FSPartials returns a PartialFunc that finds partials within fsys, eg. an
+// embed.FS. {{>shared/header}} is looked up as shared/header followed by
+// shared/header with each of the extensions, within each of the paths.
+// Partials are cached after they are first read.
+func FSPartials(fsys fs.FS, opts FSOptions) PartialFunc {
+	return newFSLoader(fsys, opts).partial
+}
+
+// fsLoader loads, and caches, templates from an fs.FS
+type fsLoader struct {
+	fsys fs.FS
+	opts FSOptions
+
+	mu    sync.RWMutex
+	cache map[string]*fsEntry
+}
+
+// fsEntry is a cached template, path is the file it was read from
+type fsEntry struct {
+	b       []byte
+	path    string
+	modTime time.Time
+}
+
+func newFSLoader(fsys fs.FS, opts FSOptions) *fsLoader {
+	return &fsLoader{
+		fsys:  fsys,
+		opts:  opts,
+		cache: make(map[string]*fsEntry),
+	}
+}
+
+// partial returns the partial as a File, so it is rendered as a template
+func (l *fsLoader) partial(name string) (io.Reader, error) {
+	b, err := l.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+func (l *fsLoader) load(name string) ([]byte, error) {
+	l.mu.RLock()
+	e, ok := l.cache[name]
+	l.mu.RUnlock()
+	if ok && !(l.opts.Reload && l.modified(e)) {
+		return e.b, nil
+	}
+
+	e, err := l.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.cache[name] = e
+	l.mu.Unlock()
+
+	return e.b, nil
+}
+
+// modified checks if the file of a cached template has changed, or is gone
+func (l *fsLoader) modified(e *fsEntry) bool {
+	fi, err := fs.Stat(l.fsys, e.path)
+	if err != nil {
+		return true
+	}
+
+	return !fi.ModTime().Equal(e.modTime)
+}
+
+// read reads the first file found for name
+func (l *fsLoader) read(name string) (*fsEntry, error) {
+	paths := l.opts.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	exts := append([]string{""}, l.opts.Extensions...)
+
+	for _, p := range paths {
+		for _, ext := range exts {
+			e, err := l.readFile(path.Join(p, name+ext))
+			if err == nil {
+				return e, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, &fs.PathError{
+		Op:   "open",
+		Path: name,
+		Err:  fs.ErrNotExist,
+	}
+}
+
+func (l *fsLoader) readFile(name string) (*fsEntry, error) {
+	f, err := l.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fsEntry{b: b, path: name, modTime: fi.ModTime()}, nil
+}
@@ -0,0 +1,168 @@
+package beard
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFSPartials(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/shared/header.mustache": {Data: []byte(`<h1>{{title}}</h1>{{>footer}}`)},
+		"views/shared/header.html":     {Data: []byte(`wrong extension`)},
+		"partials/footer.html":         {Data: []byte(`<footer>{{>shared/note.txt}}</footer>`)},
+		"views/shared/note.txt":        {Data: []byte(`note`)},
+	}
+
+	html := `{{>shared/header}}`
+	data := map[string]interface{}{
+		"title": "Hello",
+	}
+
+	var exp = `<h1>Hello</h1><footer>note</footer>`
+
+	tmpl := Render(
+		bytes.NewReader([]byte(html)),
+		data,
+		FSPartials(fsys, FSOptions{
+			Extensions: []string{".mustache", ".html"},
+			Paths:      []string{"views", "partials"},
+		}),
+	).(*Template)
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestFSPartialsCaches(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a": {Data: []byte(`a`)},
+	}
+
+	fn := FSPartials(fsys, FSOptions{})
+
+	r, err := fn("a")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if _, ok := r.(File); !ok {
+		t.Errorf("expected partial to be a File")
+	}
+
+	fsys["a"] = &fstest.MapFile{Data: []byte(`b`)}
+
+	r, _ = fn("a")
+
+	b, _ := ioutil.ReadAll(r)
+	if got := string(b); got != "a" {
+		t.Errorf("expected cached partial, got %s", got)
+	}
+}
+
+func TestFSPartialsReload(t *testing.T) {
+	now := time.Now()
+
+	fsys := fstest.MapFS{
+		"a.html": {Data: []byte(`a`), ModTime: now},
+	}
+
+	fn := FSPartials(fsys, FSOptions{
+		Extensions: []string{".html"},
+		Reload:     true,
+	})
+
+	read := func() (string, error) {
+		r, err := fn("a")
+		if err != nil {
+			return "", err
+		}
+		b, err := ioutil.ReadAll(r)
+
+		return string(b), err
+	}
+
+	for _, v := range []struct {
+		file *fstest.MapFile
+		exp  string
+	}{
+		{nil, "a"},
+		{&fstest.MapFile{Data: []byte(`b`), ModTime: now}, "a"},
+		{&fstest.MapFile{Data: []byte(`c`), ModTime: now.Add(time.Second)}, "c"},
+	} {
+		if v.file != nil {
+			fsys["a.html"] = v.file
+		}
+
+		got, err := read()
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if v.exp != got {
+			t.Errorf("expected %s, got %s", v.exp, got)
+		}
+	}
+
+	delete(fsys, "a.html")
+
+	if _, err := read(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestSetFSReload(t *testing.T) {
+	now := time.Now()
+
+	fsys := fstest.MapFS{
+		"page.html": {Data: []byte(`<h1>{{title}}</h1>`), ModTime: now},
+	}
+
+	s := NewSet(FSPartials(fsys, FSOptions{
+		Extensions: []string{".html"},
+		Reload:     true,
+	}))
+
+	data := map[string]interface{}{
+		"title": "Hello",
+	}
+
+	for _, v := range []struct {
+		src string
+		exp string
+	}{
+		{"", `<h1>Hello</h1>`},
+		{`<h2>{{title}}</h2>`, `<h2>Hello</h2>`},
+	} {
+		if v.src != "" {
+			fsys["page.html"] = &fstest.MapFile{
+				Data:    []byte(v.src),
+				ModTime: now.Add(time.Second),
+			}
+		}
+
+		r, err := s.Render("page", data)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		Asser{t}.
+			Given(a(r.(*Template))).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestFSPartialsNotFound(t *testing.T) {
+	fn := FSPartials(fstest.MapFS{}, FSOptions{
+		Extensions: []string{".html"},
+	})
+
+	_, err := fn("a")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
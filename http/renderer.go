@@ -0,0 +1,225 @@
+// Package http renders the templates of a beard.Set as HTTP responses.
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/nowk/beard"
+)
+
+// DefaultBufferSize is the size of the chunks a Renderer writes and flushes
+const DefaultBufferSize = 4 << 10
+
+// defaultContentType is the Content-Type of templates without a known
+// extension
+const defaultContentType = "text/html; charset=utf-8"
+
+// Renderer renders the templates of a Set to a http.ResponseWriter. The output
+// is streamed in chunks of BufferSize, each is flushed when the
+// ResponseWriter is a http.Flusher, so large pages start to be sent early.
+type Renderer struct {
+	// Set is the set of templates, partials and layouts
+	Set *beard.Set
+
+	// Layout is the name of the default layout. Templates are rendered within
+	// it when they share its extension, eg. users.html within layout.html, but
+	// not users.json.
+	Layout string
+
+	// BufferSize is the size of the chunks written, DefaultBufferSize is used
+	// when it is 0. An error rendering the first chunk is responded to with a
+	// 500.
+	BufferSize int
+
+	// ErrorLog logs the errors of the Handlers, which cannot return them, eg.
+	// an error rendering a template. The log package's standard logger is used
+	// when it is nil. Errors of requests which have been canceled are not
+	// logged.
+	ErrorLog *log.Logger
+}
+
+// NewRenderer returns a Renderer of the templates of set, rendered within the
+// layout, which may be empty.
+func NewRenderer(set *beard.Set, layout string) *Renderer {
+	return &Renderer{
+		Set:    set,
+		Layout: layout,
+	}
+}
+
+// Render renders the template name with data and writes it to w with status.
+// The Content-Type is set by the template's extension and variables are
+// escaped to suit it, eg. a .json template is escaped with beard.JSONEscape.
+//
+// Errors before the first chunk is written are responded to with a 500 and
+// returned, errors after can only be returned as the status has been sent.
+func (r *Renderer) Render(
+	w http.ResponseWriter, status int, name string, data interface{}) error {
+
+	return r.render(context.Background(), w, status, name, data)
+}
+
+// DataFunc returns the data a template is rendered with for a request
+type DataFunc func(*http.Request) (interface{}, error)
+
+// Handler returns a http.Handler rendering the template name with the data
+// returned by fn, which may be nil. Rendering stops once the request's context
+// is done. An error returned by fn is responded to with a 500. Errors are
+// logged to ErrorLog.
+func (r *Renderer) Handler(name string, fn DataFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var data interface{}
+		if fn != nil {
+			var err error
+			data, err = fn(req)
+			if err != nil {
+				internalError(w)
+				r.logf(req, "%s: data: %v", name, err)
+
+				return
+			}
+		}
+
+		err := r.render(req.Context(), w, http.StatusOK, name, data)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			r.logf(req, "%s: %v", name, err)
+		}
+	})
+}
+
+// logf logs an error of the request to ErrorLog
+func (r *Renderer) logf(req *http.Request, format string, args ...interface{}) {
+	format = "beard/http: %s %s: " + format
+	args = append([]interface{}{req.Method, req.URL.Path}, args...)
+	if r.ErrorLog != nil {
+		r.ErrorLog.Printf(format, args...)
+
+		return
+	}
+
+	log.Printf(format, args...)
+}
+
+func (r *Renderer) render(ctx context.Context,
+	w http.ResponseWriter, status int, name string, data interface{}) error {
+
+	te, err := r.template(name)
+	if err != nil {
+		internalError(w)
+
+		return err
+	}
+	te.Data.Value = data
+	te.Context(ctx)
+	te.Escaper(escaper(name))
+
+	buf := make([]byte, r.bufferSize())
+
+	// the first chunk is rendered before the header is written, so an early
+	// error can still be responded to with a 500
+	n, err := fill(te, buf)
+	if err != nil && err != io.EOF {
+		internalError(w)
+
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType(name))
+	w.WriteHeader(status)
+
+	for {
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		n, err = fill(te, buf)
+	}
+}
+
+// template returns the template name, within the default layout when it
+// shares the layout's extension
+func (r *Renderer) template(name string) (*beard.Template, error) {
+	var (
+		rd  io.Reader
+		err error
+	)
+	if r.Layout != "" && path.Ext(name) == path.Ext(r.Layout) {
+		rd, err = r.Set.RenderInLayouts(name, nil, r.Layout)
+	} else {
+		rd, err = r.Set.Render(name, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rd.(*beard.Template), nil
+}
+
+func (r *Renderer) bufferSize() int {
+	if r.BufferSize > 0 {
+		return r.BufferSize
+	}
+
+	return DefaultBufferSize
+}
+
+// fill reads from r until buf is full or r returns an error
+func fill(r io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		i, err := r.Read(buf[n:])
+		n += i
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// contentType returns the Content-Type of the template name by its extension
+func contentType(name string) string {
+	if typ := mime.TypeByExtension(path.Ext(name)); typ != "" {
+		return typ
+	}
+
+	return defaultContentType
+}
+
+// escapers are the Escapers of the templates by extension, the others are
+// escaped as HTML
+var escapers = map[string]beard.Escaper{
+	".json": beard.JSONEscape,
+	".csv":  beard.CSVEscape,
+	".txt":  beard.NoEscape,
+}
+
+func escaper(name string) beard.Escaper {
+	if e, ok := escapers[path.Ext(name)]; ok {
+		return e
+	}
+
+	return beard.HTMLEscape
+}
+
+func internalError(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError),
+		http.StatusInternalServerError)
+}
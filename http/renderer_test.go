@@ -0,0 +1,221 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nowk/beard"
+)
+
+func newSet(t *testing.T, templates map[string]string) *beard.Set {
+	set := beard.NewSet(nil)
+	for name, src := range templates {
+		if err := set.Define(name, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return set
+}
+
+type user struct {
+	Name string
+}
+
+func TestRender(t *testing.T) {
+	set := newSet(t, map[string]string{
+		"layout.html": "<body>{{>yield}}</body>",
+		"user.html":   "<h1>{{Name}}</h1>",
+		"user.json":   `{"name": "{{Name}}"}`,
+		"user.txt":    "{{Name}}",
+	})
+
+	for _, v := range []struct {
+		name, contentType, body string
+	}{
+		{
+			"user.html",
+			"text/html; charset=utf-8",
+			"<body><h1>&lt;b&gt;&#34;Batman&#34;</h1></body>",
+		},
+		{
+			"user.json",
+			"application/json",
+			`{"name": "<b>\"Batman\""}`,
+		},
+		{
+			"user.txt",
+			"text/plain; charset=utf-8",
+			`<b>"Batman"`,
+		},
+	} {
+		w := httptest.NewRecorder()
+
+		err := NewRenderer(set, "layout.html").
+			Render(w, http.StatusCreated, v.name, user{`<b>"Batman"`})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			exp = v.body
+			got = w.Body.String()
+		)
+		if exp != got {
+			t.Errorf("expected %s, got %s", exp, got)
+		}
+		if w.Code != http.StatusCreated {
+			t.Errorf("expected %d, got %d", http.StatusCreated, w.Code)
+		}
+		if typ := w.Header().Get("Content-Type"); typ != v.contentType {
+			t.Errorf("expected %s, got %s", v.contentType, typ)
+		}
+	}
+}
+
+func TestRenderFlushesChunks(t *testing.T) {
+	set := newSet(t, map[string]string{
+		"list.html": "{{#items}}<li>{{.}}</li>{{/items}}",
+	})
+
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	w := httptest.NewRecorder()
+
+	r := &Renderer{Set: set, BufferSize: 64}
+	err := r.Render(w, http.StatusOK, "list.html", map[string]interface{}{
+		"items": items,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+
+	got := w.Body.String()
+	if !strings.HasPrefix(got, "<li>0</li><li>1</li>") ||
+		!strings.HasSuffix(got, "<li>99</li>") {
+		t.Errorf("unexpected body %s", got)
+	}
+}
+
+func TestRenderErrorBeforeFirstByte(t *testing.T) {
+	set := newSet(t, map[string]string{
+		"page.html": "<h1>Page</h1>{{>missing}}",
+	})
+
+	w := httptest.NewRecorder()
+
+	err := NewRenderer(set, "").Render(w, http.StatusOK, "page.html", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if got := w.Body.String(); strings.Contains(got, "<h1>Page</h1>") {
+		t.Errorf("expected no partial page, got %s", got)
+	}
+}
+
+func TestRenderNotDefined(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	err := NewRenderer(newSet(t, nil), "").
+		Render(w, http.StatusOK, "missing.html", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	set := newSet(t, map[string]string{
+		"hello.html": "Hello {{name}}!",
+	})
+
+	h := NewRenderer(set, "").Handler("hello.html",
+		func(req *http.Request) (interface{}, error) {
+			return map[string]interface{}{
+				"name": req.URL.Query().Get("name"),
+			}, nil
+		})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/?name=Robin", nil))
+
+	if got := w.Body.String(); got != "Hello Robin!" {
+		t.Errorf("expected %s, got %s", "Hello Robin!", got)
+	}
+}
+
+func TestHandlerCanceled(t *testing.T) {
+	set := newSet(t, map[string]string{
+		"hello.html": "Hello!",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := httptest.NewRecorder()
+	NewRenderer(set, "").Handler("hello.html", nil).
+		ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestHandlerLogsErrors(t *testing.T) {
+	set := newSet(t, map[string]string{
+		"hello.html": "Hello {{name}}!",
+		"page.html":  "<h1>Page</h1>{{>missing}}",
+	})
+
+	for _, v := range []struct {
+		name string
+		fn   DataFunc
+		exp  string
+	}{
+		{"hello.html", func(*http.Request) (interface{}, error) {
+			return nil, errors.New("no user")
+		}, "beard/http: GET /users: hello.html: data: no user\n"},
+		{"page.html", nil, "beard/http: GET /users: page.html: "},
+		{"hello.html", nil, ""},
+	} {
+		var buf bytes.Buffer
+
+		r := NewRenderer(set, "")
+		r.ErrorLog = log.New(&buf, "", 0)
+
+		w := httptest.NewRecorder()
+		r.Handler(v.name, v.fn).ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+
+		got := buf.String()
+		if v.exp == "" {
+			if got != "" {
+				t.Errorf("expected nothing to be logged, got %q", got)
+			}
+
+			continue
+		}
+		if !strings.HasPrefix(got, v.exp) {
+			t.Errorf("expected %q to be logged, got %q", v.exp, got)
+		}
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	}
+}
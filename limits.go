@@ -0,0 +1,159 @@
+package beard
+
+import (
+	"fmt"
+	"io"
+)
+
+// Limits bound the work done rendering a template, eg. a template authored by
+// a user. A limit of 0 is unlimited.
+type Limits struct {
+	// MaxOutputBytes is the max number of bytes written. Content yielded by a
+	// layout is counted each time it is yielded, the content captured to be
+	// yielded is held to the limit separately.
+	MaxOutputBytes int64
+
+	// MaxIterations is the max number of block iterations, across all of the
+	// blocks rendered
+	MaxIterations int64
+
+	// MaxDepth is the max depth blocks and partials can be nested
+	MaxDepth int64
+
+	// MaxPartials is the max number of partials rendered
+	MaxPartials int64
+}
+
+// LimitError is returned when rendering exceeds one of the template's Limits.
+// Limit is the name of the limit exceeded, eg. MaxOutputBytes.
+type LimitError struct {
+	Limit string
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d exceeded", e.Limit, e.Max)
+}
+
+// limiter counts the work done rendering a template, it is shared by its
+// partials.
+type limiter struct {
+	Limits
+
+	output     int64
+	captured   int64
+	iterations int64
+	partials   int64
+}
+
+func (l *limiter) check(name string, n, max int64) error {
+	if max > 0 && n > max {
+		return &LimitError{Limit: name, Max: max}
+	}
+
+	return nil
+}
+
+// Limits sets the limits of rendering the template. The limits are shared by
+// its partials.
+func (t *Template) Limits(l Limits) {
+	t.limits = &limiter{Limits: l}
+}
+
+// limiter returns the limiter of the template, or of its closest parent
+func (t *Template) limiter() *limiter {
+	for te := t; te != nil; te = te.parent {
+		if te.limits != nil {
+			return te.limits
+		}
+	}
+
+	return nil
+}
+
+// countOutput counts n bytes written by the template. Bytes captured to be
+// yielded by a layout are counted apart from the output, as they are counted
+// when they are yielded.
+func (t *Template) countOutput(n int) error {
+	l := t.limiter()
+	if l == nil {
+		return nil
+	}
+	if t.capturing() {
+		l.captured += int64(n)
+
+		return l.check("MaxOutputBytes", l.captured, l.MaxOutputBytes)
+	}
+	l.output += int64(n)
+
+	return l.check("MaxOutputBytes", l.output, l.MaxOutputBytes)
+}
+
+// capturing returns true if the template, or one of its parents, is captured
+// to be yielded by a layout
+func (t *Template) capturing() bool {
+	for te := t; te != nil; te = te.parent {
+		if te.captured {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outputReader counts the bytes read from Reader as output of t, eg. content
+// yielded by a layout
+type outputReader struct {
+	io.Reader
+	t *Template
+}
+
+func (r *outputReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if cerr := r.t.countOutput(n); cerr != nil {
+		return n, cerr
+	}
+
+	return n, err
+}
+
+// countIteration counts an iteration of a block
+func (t *Template) countIteration() error {
+	l := t.limiter()
+	if l == nil {
+		return nil
+	}
+	l.iterations++
+
+	return l.check("MaxIterations", l.iterations, l.MaxIterations)
+}
+
+// countPartial counts a partial rendered by the template
+func (t *Template) countPartial() error {
+	l := t.limiter()
+	if l == nil {
+		return nil
+	}
+	l.partials++
+
+	return l.check("MaxPartials", l.partials, l.MaxPartials)
+}
+
+// checkDepth checks that nesting another block or partial within the template
+// does not exceed the max depth.
+func (t *Template) checkDepth() error {
+	l := t.limiter()
+	if l == nil || l.MaxDepth == 0 {
+		return nil
+	}
+
+	var n int64 = 1
+	for te := t; te != nil; te = te.parent {
+		n += int64(len(te.blocks))
+		if te.parent != nil {
+			n++
+		}
+	}
+
+	return l.check("MaxDepth", n, l.MaxDepth)
+}
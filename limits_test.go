@@ -0,0 +1,117 @@
+package beard
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestTemplateLimits(t *testing.T) {
+	ten := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	data := map[string]interface{}{
+		"a": ten,
+		"b": ten,
+		"c": ten,
+	}
+
+	html := `{{#a}}{{#b}}{{#c}}xx{{/c}}{{/b}}{{/a}}`
+
+	for _, v := range []struct {
+		html   string
+		limits Limits
+		limit  string
+	}{
+		{html, Limits{}, ""},
+		{html, Limits{MaxOutputBytes: 2000, MaxIterations: 1110, MaxDepth: 3}, ""},
+		{html, Limits{MaxOutputBytes: 100}, "MaxOutputBytes"},
+		{html, Limits{MaxIterations: 500}, "MaxIterations"},
+		{html, Limits{MaxDepth: 2}, "MaxDepth"},
+		{`{{#a}}{{>p}}{{/a}}`, Limits{MaxPartials: 10}, ""},
+		{`{{#a}}{{>p}}{{/a}}`, Limits{MaxPartials: 5}, "MaxPartials"},
+		{`{{#a}}{{>p}}{{/a}}`, Limits{MaxDepth: 2}, ""},
+		{`{{#a}}{{>p}}{{/a}}`, Limits{MaxDepth: 1}, "MaxDepth"},
+		{`{{>q}}`, Limits{MaxOutputBytes: 3000}, ""},
+		{`{{>q}}`, Limits{MaxOutputBytes: 100}, "MaxOutputBytes"},
+		{`{{>q}}`, Limits{MaxDepth: 3}, ""},
+		{`{{>q}}`, Limits{MaxDepth: 2}, "MaxDepth"},
+		{`{{#if a}}{{#if b}}{{#if c}}x{{/if}}{{/if}}{{/if}}`, Limits{MaxDepth: 3}, ""},
+		{`{{#if a}}{{#if b}}{{#if c}}x{{/if}}{{/if}}{{/if}}`, Limits{MaxDepth: 2}, "MaxDepth"},
+		{`{{$x}}{{$y}}{{$z}}x{{/z}}{{/y}}{{/x}}`, Limits{MaxDepth: 3}, ""},
+		{`{{$x}}{{$y}}{{$z}}x{{/z}}{{/y}}{{/x}}`, Limits{MaxDepth: 2}, "MaxDepth"},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: data},
+		}
+		tmpl.Limits(v.limits)
+		tmpl.Partial(func(path string) (io.Reader, error) {
+			if path == "q" {
+				return bytes.NewReader([]byte(`{{#a}}{{#b}}xx{{/b}}{{/a}}`)), nil
+			}
+
+			return bytes.NewReader([]byte(`<p>{{.}}</p>`)), nil
+		})
+
+		_, err := io.Copy(ioutil.Discard, tmpl)
+		if v.limit == "" {
+			if err != nil {
+				t.Errorf("expected no error for %v, got %s", v.limits, err)
+			}
+
+			continue
+		}
+
+		lerr, ok := err.(*LimitError)
+		if !ok {
+			t.Errorf("expected a limit error for %v, got %v", v.limits, err)
+
+			continue
+		}
+		if v.limit != lerr.Limit {
+			t.Errorf("expected %s to be exceeded, got %s", v.limit, lerr.Limit)
+		}
+	}
+}
+
+func TestTemplateLimitsYield(t *testing.T) {
+	yields := `{{>yield}}{{>yield}}{{>yield}}{{>yield}}{{>yield}}`
+	named := `{{>yield}}{{>yield a}}{{>yield a}}`
+	inner := `0123456789{{#content_for a}}abcdefghij{{/content_for}}`
+
+	for _, v := range []struct {
+		layout string
+		max    int64
+		limit  string
+	}{
+		// only the bytes yielded are counted as output
+		{yields, 50, ""},
+		{yields, 49, "MaxOutputBytes"},
+		{yields, 20, "MaxOutputBytes"},
+		{named, 30, ""},
+		{named, 29, "MaxOutputBytes"},
+
+		// content captured to be yielded is held to the limit separately
+		{`{{>yield a}}`, 20, ""},
+		{`{{>yield a}}`, 19, "MaxOutputBytes"},
+	} {
+		tmpl := RenderInLayout(
+			bytes.NewReader([]byte(v.layout)),
+			bytes.NewReader([]byte(inner)), nil, nil).(*Template)
+		tmpl.Limits(Limits{MaxOutputBytes: v.max})
+
+		_, err := io.Copy(ioutil.Discard, tmpl)
+		if v.limit == "" {
+			if err != nil {
+				t.Errorf("expected no error for %d, got %s", v.max, err)
+			}
+
+			continue
+		}
+
+		lerr, ok := err.(*LimitError)
+		if !ok || lerr.Limit != v.limit {
+			t.Errorf("expected %s to be exceeded for %d, got %v", v.limit, v.max, err)
+		}
+	}
+}
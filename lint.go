@@ -0,0 +1,283 @@
+package beard
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint checks the template src without rendering it, returning each of the
+// problems found in order of their offset. found reports whether a partial
+// exists, partials are not checked when it is nil.
+func Lint(src []byte, found func(name string) bool) []*SyntaxError {
+	l := &linter{
+		src:   src,
+		found: found,
+	}
+	l.lint()
+
+	sort.SliceStable(l.errs, func(i, j int) bool {
+		return l.errs[i].Offset < l.errs[j].Offset
+	})
+
+	return l.errs
+}
+
+type linter struct {
+	src   []byte
+	found func(string) bool
+
+	stack []*lintSection
+	errs  []*SyntaxError
+}
+
+// lintSection is a section opened at pos, with the aliases declared by its as
+type lintSection struct {
+	sigil byte
+	name  string
+	pos   int
+
+	aliases []string
+	used    map[string]bool
+}
+
+func (l *linter) errorf(pos int, format string, a ...interface{}) {
+	l.errs = append(l.errs, &SyntaxError{pos, fmt.Sprintf(format, a...)})
+}
+
+func (l *linter) lint() {
+	i := 0
+	for {
+		o := bytes.Index(l.src[i:], ldelim.Value())
+		if o == -1 {
+			break
+		}
+
+		tag, start, end, ok := nextTag(l.src, i)
+		if !ok {
+			l.errorf(i+o, "unclosed tag")
+
+			break
+		}
+
+		// a tag opened within another tag means the first was not closed
+		pos := start + len(ldelim.Value())
+		if k := bytes.Index(tag, ldelim.Value()); k != -1 {
+			l.errorf(start, "unclosed tag")
+			i = pos + k
+
+			continue
+		}
+		i = end
+
+		l.tag(tag, start)
+	}
+
+	for _, se := range l.stack {
+		l.errorf(se.pos, "unclosed section %c%s", se.sigil, se.name)
+	}
+}
+
+func (l *linter) tag(tag []byte, start int) {
+	pos := start + len(ldelim.Value())
+
+	trimmed := bytes.TrimSpace(tag)
+	if len(trimmed) == 0 {
+		l.errorf(start, "empty tag")
+
+		return
+	}
+	if trimmed[0] == '{' {
+		l.errorf(start, "triple mustaches are not supported, use {{&name}}")
+
+		return
+	}
+
+	if kw, cond, j, ok := parseCond(tag); ok {
+		if _, err := parseExpr(cond, pos+j); err != nil {
+			l.syntaxError(err, start)
+		}
+		l.use(cond)
+		if kw == ifTag {
+			sigil, name := sectionName(tag)
+			l.open(sigil, name, start, nil)
+		}
+
+		return
+	}
+	if _, ok := parseKeyword(tag, yieldTag); ok {
+		return
+	}
+	if name, args, ok, err := parsePartial(tag, pos); err != nil || ok {
+		if err != nil {
+			l.syntaxError(err, start)
+
+			return
+		}
+
+		l.partial(name, start)
+		if !args.isolated {
+			l.useAll()
+		}
+		l.use(tag[bytes.Index(tag, []byte(name))+len(name):])
+
+		return
+	}
+
+	sigil, name := sectionName(tag)
+	switch sigil {
+	case 0:
+		if trimmed[0] == '>' {
+			l.partial(string(bytes.TrimSpace(trimmed[1:])), start)
+			l.useAll()
+
+			return
+		}
+
+		l.use(trimmed)
+
+	case '/':
+		l.close(name, start)
+
+	case '#', '^':
+		key, _, err := parseMods(tag, pos)
+		if err != nil {
+			l.syntaxError(err, start)
+		}
+
+		// parseTag cleans the spaces of the tag in place
+		key, as := parseTag(append([]byte(nil), key...))
+		l.use(key)
+		l.open(sigil, name, start, as)
+
+	case '<':
+		l.partial(name, start)
+		l.open(sigil, name, start, nil)
+
+	default:
+		l.open(sigil, name, start, nil)
+	}
+}
+
+func (l *linter) syntaxError(err error, pos int) {
+	if serr, ok := err.(*SyntaxError); ok {
+		l.errs = append(l.errs, serr)
+
+		return
+	}
+
+	l.errorf(pos, "%s", err)
+}
+
+func (l *linter) open(sigil byte, name string, pos int, as []string) {
+	se := &lintSection{
+		sigil: sigil,
+		name:  name,
+		pos:   pos,
+	}
+	for _, a := range as {
+		if a = strings.TrimSpace(a); a != "" {
+			se.aliases = append(se.aliases, a)
+		}
+	}
+	if len(se.aliases) > 0 {
+		se.used = make(map[string]bool, len(se.aliases))
+	}
+
+	l.stack = append(l.stack, se)
+}
+
+// close closes the section name, a close tag which does not match the last
+// section closes the sections opened since the section it does match
+func (l *linter) close(name string, pos int) {
+	z := len(l.stack) - 1
+	if z < 0 {
+		l.errorf(pos, "{{/%s}} closes no section", name)
+
+		return
+	}
+	if se := l.stack[z]; se.name != name {
+		l.errorf(pos, "{{/%s}} does not close %c%s", name, se.sigil, se.name)
+
+		for i := z - 1; i > -1; i-- {
+			if l.stack[i].name == name {
+				for _, se := range l.stack[i+1:] {
+					l.errorf(se.pos, "unclosed section %c%s", se.sigil, se.name)
+				}
+				l.pop(i)
+
+				return
+			}
+		}
+
+		return
+	}
+
+	l.pop(z)
+}
+
+// pop removes the section at i, and any after it, from the stack reporting
+// its unused aliases
+func (l *linter) pop(i int) {
+	se := l.stack[i]
+	for _, a := range se.aliases {
+		if !se.used[a] {
+			l.errorf(se.pos, "unused alias %s", a)
+		}
+	}
+
+	l.stack = l.stack[:i]
+}
+
+func (l *linter) partial(name string, pos int) {
+	if l.found == nil || strings.HasPrefix(name, dynamicPrefix) {
+		return
+	}
+	if !l.found(name) {
+		l.errorf(pos, "unknown partial %s", name)
+	}
+}
+
+// use marks the aliases referenced within b as used, an alias is referenced
+// by the first name of a path, eg. item.name
+func (l *linter) use(b []byte) {
+	for i := 0; i < len(b); {
+		if !isNameByte(b[i]) {
+			i++
+
+			continue
+		}
+
+		j := i
+		for j < len(b) && isNameByte(b[j]) {
+			j++
+		}
+		if i == 0 || b[i-1] != '.' {
+			l.mark(string(b[i:j]))
+		}
+
+		i = j
+	}
+}
+
+func (l *linter) mark(name string) {
+	for _, se := range l.stack {
+		if se.used != nil {
+			se.used[name] = true
+		}
+	}
+}
+
+// useAll marks all of the aliases as used, as partials can reference them
+func (l *linter) useAll() {
+	for _, se := range l.stack {
+		for _, a := range se.aliases {
+			se.used[a] = true
+		}
+	}
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '-' || c == '@' || isLetter(c) || c >= '0' && c <= '9'
+}
@@ -0,0 +1,57 @@
+package beard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	found := func(name string) bool {
+		return name == "header" || name == "layout"
+	}
+
+	for _, v := range []struct {
+		giv string
+		exp []*SyntaxError
+	}{
+		{`<h1>{{a}}</h1>{{#items as item}}{{item.name}}{{/items}}{{>header}}`, nil},
+		{`{{#if a > 1}}{{#b as k, v}}{{v}}{{>header}}{{/b}}{{/if}}`, nil},
+		{`{{<layout}}{{$title}}a{{/title}}{{/layout}}{{>*dynamic}}`, nil},
+		{`<script>function f(){ if (a) { b() }}</script><style>a{b:{c}}}</style>`, nil},
+		{`{{#a}}{{#b}}`, []*SyntaxError{
+			{0, "unclosed section #a"},
+			{6, "unclosed section #b"},
+		}},
+		{`{{#a}}{{#b}}{{/a}}{{/c}}`, []*SyntaxError{
+			{6, "unclosed section #b"},
+			{12, "{{/a}} does not close #b"},
+			{18, "{{/c}} closes no section"},
+		}},
+		{`a{{ }}b}}{{ c`, []*SyntaxError{
+			{1, "empty tag"},
+			{9, "unclosed tag"},
+		}},
+		{`{{a {{b}}{{{c}}}`, []*SyntaxError{
+			{0, "unclosed tag"},
+			{9, "triple mustaches are not supported, use {{&name}}"},
+		}},
+		{`{{>footer}}{{>card item}}{{<base}}{{/base}}`, []*SyntaxError{
+			{0, "unknown partial footer"},
+			{11, "unknown partial card"},
+			{25, "unknown partial base"},
+		}},
+		{`{{#items as item}}{{name}}{{/items}}{{#m as k, v}}{{k}}{{/m}}`, []*SyntaxError{
+			{0, "unused alias item"},
+			{36, "unused alias v"},
+		}},
+		{`{{#if a ==}}{{/if}}{{#items limit:a}}{{/items}}`, []*SyntaxError{
+			{10, "unexpected end of condition"},
+			{28, "limit requires a positive number"},
+		}},
+	} {
+		got := Lint([]byte(v.giv), found)
+		if !reflect.DeepEqual(v.exp, got) {
+			t.Errorf("expected %v for %s, got %v", v.exp, v.giv, got)
+		}
+	}
+}
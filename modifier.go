@@ -0,0 +1,308 @@
+package beard
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// modifier represents a block modifier, eg. sort:price desc, where:active,
+// limit:10 or offset:20
+type modifier struct {
+	name string
+	arg  string
+	opts []string
+
+	// pos is the offset of the modifier within the template
+	pos int
+}
+
+const (
+	modSort   = "sort"
+	modWhere  = "where"
+	modLimit  = "limit"
+	modOffset = "offset"
+)
+
+// parseMods splits the modifiers off of a block tag. pos is the offset of the
+// tag within the template.
+func parseMods(tag []byte, pos int) ([]byte, []modifier, error) {
+	if bytes.IndexByte(tag, ':') == -1 {
+		return tag, nil, nil
+	}
+
+	var (
+		mods []modifier
+		kept [][]byte
+
+		// sortOpts is set while the words are the options of a sort
+		sortOpts bool
+	)
+
+	i := 0
+	for i < len(tag) {
+		i = skipSpaces(tag, i)
+		if i == len(tag) {
+			break
+		}
+		j := bytes.IndexByte(tag[i:], ' ')
+		if j == -1 {
+			j = len(tag)
+		} else {
+			j += i
+		}
+		word := tag[i:j]
+
+		if k := bytes.IndexByte(word, ':'); k != -1 {
+			m := modifier{
+				name: string(word[:k]),
+				arg:  string(word[k+1:]),
+				pos:  pos + i,
+			}
+			if err := m.validate(); err != nil {
+				return nil, nil, err
+			}
+
+			mods = append(mods, m)
+			sortOpts = m.name == modSort
+		} else if sortOpts && string(word) != "as" {
+			// words following a sort are its options, up to an as or the
+			// next modifier
+			n := len(mods)
+			mods[n-1].opts = append(mods[n-1].opts, string(word))
+		} else {
+			sortOpts = false
+			kept = append(kept, word)
+		}
+
+		i = j
+	}
+
+	return bytes.Join(kept, []byte(" ")), mods, nil
+}
+
+func (m *modifier) validate() error {
+	switch m.name {
+	case modSort, modWhere:
+		if m.arg == "" || m.arg == "!" {
+			return &SyntaxError{m.pos, fmt.Sprintf("%s requires a path", m.name)}
+		}
+
+	case modLimit, modOffset:
+		n, err := strconv.Atoi(m.arg)
+		if err != nil || n < 0 {
+			return &SyntaxError{m.pos,
+				fmt.Sprintf("%s requires a positive number", m.name)}
+		}
+
+	default:
+		return &SyntaxError{m.pos, fmt.Sprintf("unknown modifier %q", m.name)}
+	}
+
+	return nil
+}
+
+// entry is an item of the block's data being modified. key is only set when
+// iterating over the keys of a map.
+type entry struct {
+	key   reflect.Value
+	value interface{}
+
+	// sortKey is the value the entry is sorted by
+	sortKey interface{}
+}
+
+// applyMods filters, sorts and slices the data of a block. Modifiers are always
+// applied in the order of where, sort, offset and then limit.
+func (t *Template) applyMods(
+	data *Data, mods []modifier, as ...string) (*Data, error) {
+
+	if data == nil || len(mods) == 0 {
+		return data, nil
+	}
+
+	keyValue := len(as) == 2
+
+	val := reflect.ValueOf(data.Value)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	var entries []entry
+
+	om, ordered := data.Value.(OrderedMap)
+
+	switch {
+	case ordered && keyValue:
+		keys := orderedKeys(om)
+		entries = make([]entry, 0, len(keys))
+		for _, k := range keys {
+			v, _ := om.Get(k.String())
+
+			entries = append(entries, entry{key: k, value: v})
+		}
+
+	case val.Kind() == reflect.Slice:
+		n := val.Len()
+		entries = make([]entry, 0, n)
+		for i := 0; i < n; i++ {
+			entries = append(entries, entry{value: val.Index(i).Interface()})
+		}
+
+	case val.Kind() == reflect.Map && keyValue:
+		keys := data.mapKeys(val)
+		entries = make([]entry, 0, len(keys))
+		for _, k := range keys {
+			entries = append(entries, entry{
+				key:   k,
+				value: valueInterface(val.MapIndex(k)),
+			})
+		}
+
+	default:
+		// only lists and key/value maps can be modified
+		return data, nil
+	}
+
+	for _, m := range mods {
+		if m.name != modWhere {
+			continue
+		}
+
+		path, not := m.arg, false
+		if path[0] == '!' {
+			path, not = path[1:], true
+		}
+
+		z := 0
+		for _, e := range entries {
+			v, err := e.get(t, path, as)
+			if err != nil {
+				return nil, err
+			}
+			if truthy(v) != not {
+				entries[z] = e
+				z++
+			}
+		}
+		entries = entries[:z]
+	}
+
+	for _, m := range mods {
+		if m.name != modSort {
+			continue
+		}
+
+		var (
+			fn   = compareAuto
+			desc = false
+		)
+		for _, o := range m.opts {
+			switch o {
+			case "asc":
+				desc = false
+			case "desc":
+				desc = true
+
+			default:
+				f, ok := t.comparator(o)
+				if !ok {
+					return nil, &SyntaxError{m.pos,
+						fmt.Sprintf("unknown comparator %q", o)}
+				}
+				fn = f
+			}
+		}
+
+		// each key is looked up once, before sorting
+		for i, e := range entries {
+			v, err := e.get(t, m.arg, as)
+			if err != nil {
+				return nil, err
+			}
+			entries[i].sortKey = v
+		}
+
+		sort.SliceStable(entries, func(i, j int) bool {
+			n := fn(entries[i].sortKey, entries[j].sortKey)
+			if desc {
+				return n > 0
+			}
+
+			return n < 0
+		})
+	}
+
+	for _, m := range mods {
+		if m.name != modOffset {
+			continue
+		}
+
+		n, _ := strconv.Atoi(m.arg)
+		if n > len(entries) {
+			n = len(entries)
+		}
+		entries = entries[n:]
+	}
+
+	for _, m := range mods {
+		if m.name != modLimit {
+			continue
+		}
+
+		n, _ := strconv.Atoi(m.arg)
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+
+	if keyValue && (ordered || val.Kind() == reflect.Map) {
+		keys := make([]reflect.Value, 0, len(entries))
+		for _, e := range entries {
+			keys = append(keys, e.key)
+		}
+
+		d := &Data{Value: data.Value, keys: keys}
+		d.As(as...)
+
+		return d, nil
+	}
+
+	items := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, e.value)
+	}
+
+	return &Data{Value: items}, nil
+}
+
+// get returns the value of path for the entry. When iterating over a map's
+// keys, the key name (or @key) refers to the key and the value name to the
+// value. A Lambda is called for its value.
+func (e entry) get(t *Template, path string, as []string) (interface{}, error) {
+	if e.key.IsValid() {
+		if path == "@key" || path == as[0] {
+			return valueInterface(e.key), nil
+		}
+		if path == as[1] {
+			path = "."
+		} else {
+			path = strings.TrimPrefix(path, as[1]+".")
+		}
+	}
+
+	d := &Data{Value: e.value}
+	if len(as) == 1 {
+		d.As(as...)
+	}
+
+	v, err := t.call(d.Get(path))
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	return v.Value, nil
+}
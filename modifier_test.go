@@ -0,0 +1,70 @@
+package beard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseMods(t *testing.T) {
+	for _, v := range []struct {
+		giv  string
+		tag  string
+		mods []modifier
+	}{
+		{"#items", "#items", nil},
+		{"#items sort:price desc limit:10 offset:20", "#items", []modifier{
+			{name: "sort", arg: "price", opts: []string{"desc"}, pos: 7},
+			{name: "limit", arg: "10", pos: 23},
+			{name: "offset", arg: "20", pos: 32},
+		}},
+		{"#post as k, v  where:!v.draft", "#post as k, v", []modifier{
+			{name: "where", arg: "!v.draft", pos: 15},
+		}},
+		{"#items sort:price desc as item", "#items as item", []modifier{
+			{name: "sort", arg: "price", opts: []string{"desc"}, pos: 7},
+		}},
+		{"#items as item sort:price desc", "#items as item", []modifier{
+			{name: "sort", arg: "price", opts: []string{"desc"}, pos: 15},
+		}},
+		{"#items sort:price desc natural where:active", "#items", []modifier{
+			{name: "sort", arg: "price", opts: []string{"desc", "natural"}, pos: 7},
+			{name: "where", arg: "active", pos: 31},
+		}},
+	} {
+		tag, mods, err := parseMods([]byte(v.giv), 0)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		if got := string(tag); v.tag != got {
+			t.Errorf("expected tag %q, got %q", v.tag, got)
+		}
+		if !reflect.DeepEqual(v.mods, mods) {
+			t.Errorf("expected %v, got %v", v.mods, mods)
+		}
+	}
+}
+
+func Test_parseModsErrors(t *testing.T) {
+	for _, v := range []struct {
+		giv string
+		pos int
+	}{
+		{"#items limit:a", 17},
+		{"#items offset:-1", 17},
+		{"#items sort:", 17},
+		{"#items where:!", 17},
+		{"#items group:a", 17},
+	} {
+		_, _, err := parseMods([]byte(v.giv), 10)
+
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected a syntax error for %s, got %v", v.giv, err)
+
+			continue
+		}
+		if v.pos != serr.Offset {
+			t.Errorf("expected error at %d, got %d", v.pos, serr.Offset)
+		}
+	}
+}
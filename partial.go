@@ -0,0 +1,151 @@
+package beard
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// partialArgs are the arguments of a partial tag, eg.
+// {{>button label="Save" kind=primary.style}} or {{>card item isolated}}
+type partialArgs struct {
+	// context is the path of the Data the partial is rendered with
+	context string
+
+	params   []param
+	isolated bool
+}
+
+type param struct {
+	name string
+	val  expr
+}
+
+const isolatedArg = "isolated"
+
+// parsePartial splits a partial tag into the partial's name and its arguments.
+// It returns false when the tag is not a partial or has no arguments. pos is
+// the offset of the tag within the template.
+func parsePartial(tag []byte, pos int) (string, *partialArgs, bool, error) {
+	i := skipSpaces(tag, 0)
+	if i == len(tag) || tag[i] != '>' {
+		return "", nil, false, nil
+	}
+	i = skipSpaces(tag, i+1)
+
+	j := bytes.IndexByte(tag[i:], ' ')
+	if j == -1 {
+		return "", nil, false, nil
+	}
+	j += i
+
+	name := string(tag[i:j])
+	if skipSpaces(tag, j) == len(tag) {
+		return "", nil, false, nil
+	}
+
+	args, err := parsePartialArgs(tag[j:], pos+j)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	return name, args, true, nil
+}
+
+// parsePartialArgs parses the arguments following a partial's name. A path on
+// its own is the context of the partial, name=value pairs are its parameters
+// and isolated keeps the partial, and its own partials, from looking up its
+// parent's scopes, including @root.
+func parsePartialArgs(b []byte, pos int) (*partialArgs, error) {
+	toks, err := lexExpr(b, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		args = &partialArgs{}
+		p    = &exprParser{toks: toks}
+	)
+	for p.peek().typ != tokEOF {
+		tok := p.next()
+		if tok.typ != tokPath {
+			return nil, &SyntaxError{tok.pos,
+				fmt.Sprintf("unexpected %q", tok.val)}
+		}
+
+		if p.peek().val == "=" {
+			p.next()
+
+			x, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			args.params = append(args.params, param{tok.val, x})
+
+			continue
+		}
+
+		switch {
+		case tok.val == isolatedArg:
+			args.isolated = true
+		case args.context != "":
+			return nil, &SyntaxError{tok.pos,
+				fmt.Sprintf("partial context already given, got %q", tok.val)}
+
+		default:
+			args.context = tok.val
+		}
+	}
+
+	return args, nil
+}
+
+// locals evaluates the arguments against the template rendering the partial
+func (a *partialArgs) locals(t *Template) (*locals, error) {
+	l := &locals{isolated: a.isolated}
+	if a.context != "" {
+		l.context = t.lookup(a.context)
+	}
+	if len(a.params) > 0 {
+		l.params = make(map[string]interface{}, len(a.params))
+		for _, p := range a.params {
+			v, err := p.val.eval(t)
+			if err != nil {
+				return nil, err
+			}
+			l.params[p.name] = v
+		}
+	}
+
+	return l, nil
+}
+
+// locals are the arguments a partial was rendered with, they are looked up
+// before the scopes of the partial's parent.
+type locals struct {
+	context  *Data
+	params   map[string]interface{}
+	isolated bool
+}
+
+// get looks up k on the parameters, then the context. Parameters shadow the
+// context and parent scopes even when their value is nil.
+func (l *locals) get(k string) *Data {
+	name, rest := k, ""
+	if i := strings.IndexByte(k, '.'); i > 0 {
+		name, rest = k[:i], k[i+1:]
+	}
+	if v, ok := l.params[name]; ok {
+		d := newData(v)
+		if d == nil || rest == "" {
+			return d
+		}
+
+		return d.Get(rest)
+	}
+	if l.context == nil {
+		return nil
+	}
+
+	return l.context.Get(k)
+}
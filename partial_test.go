@@ -0,0 +1,73 @@
+package beard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parsePartial(t *testing.T) {
+	for _, v := range []struct {
+		giv  string
+		name string
+		args *partialArgs
+		ok   bool
+	}{
+		{">card", "", nil, false},
+		{"> card ", "", nil, false},
+		{">card item", "card", &partialArgs{context: "item"}, true},
+		{">card ../item isolated", "card", &partialArgs{
+			context:  "../item",
+			isolated: true,
+		}, true},
+		{`>button label="Save" kind=primary.style`, "button", &partialArgs{
+			params: []param{
+				{"label", literalExpr{"Save"}},
+				{"kind", pathExpr{"primary.style"}},
+			},
+		}, true},
+		{`>*kind item size = 2`, "*kind", &partialArgs{
+			context: "item",
+			params:  []param{{"size", literalExpr{float64(2)}}},
+		}, true},
+	} {
+		name, args, ok, err := parsePartial([]byte(v.giv), 0)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		if v.ok != ok {
+			t.Errorf("expected %t for %s, got %t", v.ok, v.giv, ok)
+		}
+		if v.name != name {
+			t.Errorf("expected name %q, got %q", v.name, name)
+		}
+		if !reflect.DeepEqual(v.args, args) {
+			t.Errorf("expected %v, got %v", v.args, args)
+		}
+	}
+}
+
+func Test_parsePartialErrors(t *testing.T) {
+	for _, v := range []struct {
+		giv string
+		pos int
+	}{
+		{">card a b", 18},
+		{">card label=", 22},
+		{">card label=!", 22},
+		{">card =a", 16},
+		{`>card label="a`, 22},
+	} {
+		_, _, _, err := parsePartial([]byte(v.giv), 10)
+
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected a syntax error for %s, got %v", v.giv, err)
+
+			continue
+		}
+		if v.pos != serr.Offset {
+			t.Errorf("expected error at %d for %s, got %d",
+				v.pos, v.giv, serr.Offset)
+		}
+	}
+}
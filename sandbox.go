@@ -0,0 +1,312 @@
+package beard
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"reflect"
+)
+
+// Sandbox restricts what a template can access when rendering, eg. a template
+// authored by a customer.
+type Sandbox struct {
+	// Types are values of the struct and map types whose fields and keys can be
+	// looked up, eg. User{}. Only the exported fields of structs are available.
+	// map[string]interface{} and []interface{} are always allowed.
+	Types []interface{}
+
+	// Partials are the names of the partials which can be rendered
+	Partials []string
+
+	// Limits are the limits of rendering the template, DefaultSandboxLimits
+	// are used when no limits are given.
+	Limits Limits
+}
+
+// DefaultSandboxLimits are the limits of a sandboxed template when none are
+// given
+var DefaultSandboxLimits = Limits{
+	MaxOutputBytes: 10 << 20,
+	MaxIterations:  100000,
+	MaxDepth:       50,
+	MaxPartials:    1000,
+}
+
+// maxSandboxDepth is the max depth values are copied to
+const maxSandboxDepth = 64
+
+// sandbox is a Sandbox prepared for lookups
+type sandbox struct {
+	types    map[reflect.Type]bool
+	partials map[string]bool
+
+	// copies holds the copies of the structs, maps and slices visited while
+	// copying the data, so each is copied once and cycles end
+	copies map[visit]interface{}
+}
+
+// visit is a struct, map or slice copied by the sandbox
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// Sandbox renders the template within s. The template's Data is copied, once,
+// into maps, lists and basic values, so no methods are called on the data and
+// only the types allowed by s are looked into. Values which are not allowed,
+// including funcs and Lambdas, are left out. Sandbox must be called after Data
+// has been set. The Data is copied in place, so any layouts sharing it render
+// the copy, and the sandbox applies to the templates they yield to.
+func (t *Template) Sandbox(s Sandbox) {
+	sb := &sandbox{
+		types:    make(map[reflect.Type]bool, len(s.Types)),
+		partials: make(map[string]bool, len(s.Partials)),
+	}
+	for _, v := range s.Types {
+		typ := reflect.TypeOf(v)
+		for typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		sb.types[typ] = true
+	}
+	for _, name := range s.Partials {
+		sb.partials[name] = true
+	}
+	t.sandbox = sb
+
+	if t.Data != nil {
+		sb.copies = make(map[visit]interface{})
+		*t.Data = Data{Value: sb.copy(reflect.ValueOf(t.Data.Value), 0)}
+		sb.copies = nil
+	}
+
+	l := s.Limits
+	if l == (Limits{}) {
+		l = DefaultSandboxLimits
+	}
+	t.Limits(l)
+}
+
+// getSandbox returns the sandbox of the template, or of its closest parent
+func (t *Template) getSandbox() *sandbox {
+	for te := t; te != nil; te = te.parent {
+		if te.sandbox != nil {
+			return te.sandbox
+		}
+	}
+
+	return nil
+}
+
+// checkPartial checks the partial name can be rendered within the sandbox
+func (s *sandbox) checkPartial(name string) error {
+	if !s.partials[name] {
+		return fmt.Errorf("%w: %s", errPartialNotAllowed, name)
+	}
+
+	return nil
+}
+
+var (
+	htmlType     = reflect.TypeOf(HTML(""))
+	tmplHTMLType = reflect.TypeOf(template.HTML(""))
+	bytesType    = reflect.TypeOf([]byte(nil))
+	mapType      = reflect.TypeOf(map[string]interface{}(nil))
+)
+
+// copy copies v into values that can be safely looked up
+func (s *sandbox) copy(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() || depth > maxSandboxDepth {
+		return nil
+	}
+	if om, ok := s.orderedMap(v); ok {
+		return s.copyOrdered(v, om, depth)
+	}
+
+	switch v.Type() {
+	case htmlType:
+		return HTML(v.String())
+	case tmplHTMLType:
+		return template.HTML(v.String())
+	case bytesType:
+		return string(v.Bytes())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return s.copy(v.Elem(), depth)
+
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		if n := v.Uint(); n <= math.MaxInt64 {
+			return int64(n)
+		}
+
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, v.Len())
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			vis := visit{v.Type(), v.Pointer(), v.Len()}
+			if c, ok := s.copies[vis]; ok {
+				return c
+			}
+			s.copies[vis] = items
+		}
+		for i := range items {
+			items[i] = s.copy(v.Index(i), depth+1)
+		}
+
+		return items
+
+	case reflect.Map:
+		if v.Type() != mapType && !s.types[v.Type()] {
+			return nil
+		}
+
+		return s.copyMap(v, depth)
+
+	case reflect.Struct:
+		if !s.types[v.Type()] {
+			return nil
+		}
+
+		return s.copyStruct(v, depth)
+	}
+
+	// funcs, chans and the like are left out
+	return nil
+}
+
+// orderedMap returns v as an OrderedMap when its type is allowed
+func (s *sandbox) orderedMap(v reflect.Value) (OrderedMap, bool) {
+	typ := v.Type()
+	if typ.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		typ = typ.Elem()
+	}
+	if !s.types[typ] || !v.CanInterface() {
+		return nil, false
+	}
+
+	om, ok := v.Interface().(OrderedMap)
+
+	return om, ok
+}
+
+// copyOrdered copies an OrderedMap, one reached through a pointer is copied
+// once
+func (s *sandbox) copyOrdered(v reflect.Value, om OrderedMap, depth int) interface{} {
+	m := &sandboxMap{values: make(map[string]interface{})}
+	if v.Kind() == reflect.Ptr {
+		vis := visit{typ: v.Type(), ptr: v.Pointer()}
+		if c, ok := s.copies[vis]; ok {
+			return c
+		}
+		s.copies[vis] = m
+	}
+	for _, k := range om.Keys() {
+		val, _ := om.Get(k)
+
+		m.keys = append(m.keys, k)
+		m.values[k] = s.copy(reflect.ValueOf(val), depth+1)
+	}
+
+	return m
+}
+
+func (s *sandbox) copyMap(v reflect.Value, depth int) interface{} {
+	vis := visit{typ: v.Type(), ptr: v.Pointer()}
+	if c, ok := s.copies[vis]; ok {
+		return c
+	}
+
+	m := make(map[string]interface{}, v.Len())
+	s.copies[vis] = m
+
+	iter := v.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		for k.Kind() == reflect.Interface && !k.IsNil() {
+			k = k.Elem()
+		}
+
+		// only keys of basic values can be looked up
+		var key string
+		switch k.Kind() {
+		case reflect.String:
+			key = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+			reflect.Uint32, reflect.Uint64, reflect.Bool:
+			key = fmt.Sprint(s.copy(k, depth))
+
+		default:
+			continue
+		}
+
+		m[key] = s.copy(iter.Value(), depth+1)
+	}
+
+	return m
+}
+
+// copyStruct copies the exported fields of a struct, in order. A struct
+// reached through a pointer is copied once.
+func (s *sandbox) copyStruct(v reflect.Value, depth int) interface{} {
+	var vis visit
+	if v.CanAddr() {
+		vis = visit{typ: v.Type(), ptr: v.UnsafeAddr()}
+		if c, ok := s.copies[vis]; ok {
+			return c
+		}
+	}
+
+	fields := structFields(v.Type())
+
+	m := &sandboxMap{values: make(map[string]interface{}, len(fields))}
+	if v.CanAddr() {
+		s.copies[vis] = m
+	}
+	for _, f := range fields {
+		m.keys = append(m.keys, f.name)
+		m.values[f.name] = s.copy(v.FieldByIndex(f.index), depth+1)
+	}
+
+	return m
+}
+
+// sandboxMap is an OrderedMap of the values copied from a struct or an
+// OrderedMap
+type sandboxMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *sandboxMap) Keys() []string {
+	return m.keys
+}
+
+func (m *sandboxMap) Get(k string) (interface{}, bool) {
+	v, ok := m.values[k]
+
+	return v, ok
+}
+
+var errPartialNotAllowed = errors.New("partial is not allowed")
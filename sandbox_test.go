@@ -0,0 +1,220 @@
+package beard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+type sandboxUser struct {
+	Name    string
+	Age     uint8
+	Tags    []string
+	Profile *sandboxProfile
+	Secret  sandboxSecret
+	Hidden  string `beard:"-"`
+	token   string
+}
+
+type sandboxProfile struct {
+	Bio HTML
+}
+
+type sandboxSecret struct {
+	Key string
+}
+
+type sandboxName string
+
+func (n sandboxName) String() string {
+	return "method called"
+}
+
+func TestTemplateSandbox(t *testing.T) {
+	user := &sandboxUser{
+		Name:    "Bruce",
+		Age:     42,
+		Tags:    []string{"a", "b"},
+		Profile: &sandboxProfile{Bio: "<b>Bat</b>"},
+		Secret:  sandboxSecret{Key: "secret"},
+		Hidden:  "hidden",
+		token:   "token",
+	}
+
+	data := map[string]interface{}{
+		"user":  user,
+		"name":  sandboxName("Alfred"),
+		"nums":  map[int]string{1: "one"},
+		"items": []interface{}{map[string]interface{}{"a": 1}},
+		"fn": Lambda(func(context.Context) (interface{}, error) {
+			return "called", nil
+		}),
+		"ordered": &orderedMap{
+			keys:   []string{"b", "a"},
+			values: map[string]interface{}{"a": 1, "b": 2},
+		},
+	}
+
+	for _, v := range []struct {
+		html, exp string
+	}{
+		{`{{user.Name}} {{user.Age}} {{#user.Tags}}{{.}}{{/user.Tags}}`, `Bruce 42 ab`},
+		{`{{user.Profile.Bio}}`, `<b>Bat</b>`},
+		{`[{{user.Secret.Key}}{{user.Hidden}}{{user.token}}]`, `[]`},
+		{`{{#user as k, v}}{{k}},{{/user}}`, `Name,Age,Tags,Profile,Secret,`},
+		{`{{name}} [{{nums.1}}] {{#items}}{{a}}{{/items}}`, `Alfred [] 1`},
+		{`[{{fn}}]`, `[]`},
+		{`[{{#ordered as k, v}}{{k}}{{/ordered}}]`, `[]`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: data},
+		}
+		tmpl.Sandbox(Sandbox{
+			Types: []interface{}{sandboxUser{}, (*sandboxProfile)(nil)},
+		})
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateSandboxAllowedTypes(t *testing.T) {
+	data := map[string]interface{}{
+		"nums": map[int]string{1: "one"},
+		"ordered": &orderedMap{
+			keys:   []string{"b", "a"},
+			values: map[string]interface{}{"a": 1, "b": 2},
+		},
+	}
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(`{{nums.1}} {{#ordered as k, v}}{{k}}{{v}}{{/ordered}}`)),
+		Data: &Data{Value: data},
+	}
+	tmpl.Sandbox(Sandbox{
+		Types: []interface{}{map[int]string{}, orderedMap{}},
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(`one b2a1`)).
+		And(errorIs(nil))
+}
+
+func TestTemplateSandboxPartials(t *testing.T) {
+	for _, v := range []struct {
+		html string
+		err  error
+	}{
+		{`{{>a}}`, nil},
+		{`{{>b}}`, errPartialNotAllowed},
+		{`{{<b}}{{/b}}`, errPartialNotAllowed},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: map[string]interface{}{}},
+		}
+		tmpl.Partial(func(path string) (io.Reader, error) {
+			return bytes.NewReader([]byte(path)), nil
+		})
+		tmpl.Sandbox(Sandbox{
+			Partials: []string{"a"},
+		})
+
+		_, err := ioutil.ReadAll(tmpl)
+		if !errors.Is(err, v.err) {
+			t.Errorf("expected %v for %s, got %v", v.err, v.html, err)
+		}
+	}
+}
+
+func TestTemplateSandboxLimits(t *testing.T) {
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(`{{#a}}{{#a}}{{#a}}{{/a}}{{/a}}{{/a}}`)),
+		Data: &Data{Value: map[string]interface{}{
+			"a": make([]int, 100),
+		}},
+	}
+	tmpl.Sandbox(Sandbox{})
+
+	_, err := ioutil.ReadAll(tmpl)
+
+	lerr, ok := err.(*LimitError)
+	if !ok || lerr.Limit != "MaxIterations" {
+		t.Errorf("expected MaxIterations to be exceeded, got %v", err)
+	}
+}
+
+func TestTemplateSandboxLayout(t *testing.T) {
+	data := map[string]interface{}{
+		"user": &sandboxUser{Name: "Bruce", Secret: sandboxSecret{Key: "secret"}},
+	}
+	partials := func(path string) (io.Reader, error) {
+		return bytes.NewReader([]byte(path)), nil
+	}
+
+	for _, v := range []struct {
+		html string
+		exp  string
+		err  error
+	}{
+		{`[{{user.Name}}{{user.Secret.Key}}]`, `<L>[]</L>`, nil},
+		{`[{{>evil}}]`, ``, errPartialNotAllowed},
+	} {
+		tmpl := RenderInLayout(
+			bytes.NewReader([]byte(`<L>{{>yield}}</L>`)),
+			bytes.NewReader([]byte(v.html)), data, partials).(*Template)
+		tmpl.Sandbox(Sandbox{})
+
+		b, err := ioutil.ReadAll(tmpl)
+		if !errors.Is(err, v.err) {
+			t.Errorf("expected %v for %s, got %v", v.err, v.html, err)
+		}
+		if v.err == nil && string(b) != v.exp {
+			t.Errorf("expected %s for %s, got %s", v.exp, v.html, b)
+		}
+	}
+}
+
+type sandboxNode struct {
+	Name     string
+	Parent   *sandboxNode
+	Children []*sandboxNode
+}
+
+func TestTemplateSandboxCycles(t *testing.T) {
+	root := &sandboxNode{Name: "root"}
+	root.Parent = root
+	for _, name := range []string{"a", "b", "c"} {
+		n := &sandboxNode{Name: name, Parent: root}
+		for _, child := range []string{"1", "2", "3"} {
+			n.Children = append(n.Children,
+				&sandboxNode{Name: name + child, Parent: n})
+		}
+		root.Children = append(root.Children, n)
+	}
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(
+			`{{root.Parent.Parent.Name}}{{#root.Children}} {{Name}}<{{Parent.Name}}>` +
+				`{{#Children}}{{Name}}<{{Parent.Parent.Name}}>{{/Children}}{{/root.Children}}`)),
+		Data: &Data{Value: map[string]interface{}{"root": root}},
+	}
+	tmpl.Sandbox(Sandbox{
+		Types: []interface{}{sandboxNode{}},
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(`root` +
+			` a<root>a1<root>a2<root>a3<root>` +
+			` b<root>b1<root>b2<root>b3<root>` +
+			` c<root>c1<root>c2<root>c3<root>`)).
+		And(errorIs(nil))
+}
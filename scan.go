@@ -0,0 +1,207 @@
+package beard
+
+import (
+	"bytes"
+	"strings"
+)
+
+// section is a section found when scanning a template's source
+type section struct {
+	sigil byte
+	name  string
+
+	// start is the offset after the section's open tag and end is the offset
+	// of the section's close tag
+	start int
+	end   int
+}
+
+// nextTag finds the next tag in src from offset i. It returns the tag's
+// contents along with the offsets of the start and the end of the tag.
+func nextTag(src []byte, i int) ([]byte, int, int, bool) {
+	l := bytes.Index(src[i:], ldelim.Value())
+	if l == -1 {
+		return nil, 0, 0, false
+	}
+	l += i
+
+	body := l + len(ldelim.Value())
+
+	r := bytes.Index(src[body:], rdelim.Value())
+	if r == -1 {
+		return nil, 0, 0, false
+	}
+	r += body
+
+	return src[body:r], l, r + len(rdelim.Value()), true
+}
+
+// sectionName returns the sigil and name of a section tag, eg. #items as item
+// returns # and items. Tags that do not open or close a section return 0.
+func sectionName(tag []byte) (byte, string) {
+	tag = bytes.TrimSpace(tag)
+	if len(tag) == 0 {
+		return 0, ""
+	}
+
+	switch c := tag[0]; c {
+	case '#', '^', '$', '<', '/':
+		name := bytes.TrimSpace(tag[1:])
+		if c == '/' {
+			return c, strings.Replace(string(name), " ", "", -1)
+		}
+		if i := bytes.IndexByte(name, ' '); i != -1 {
+			name = name[:i]
+		}
+
+		return c, string(name)
+	}
+
+	return 0, ""
+}
+
+// scanSection scans src, the source following the open tag of the section
+// name, for its close tag. It returns the sections directly within it and the
+// offsets of the start and end of the close tag.
+func scanSection(src []byte, name string) ([]section, int, int, error) {
+	var (
+		sections []section
+		stack    []section
+	)
+
+	i := 0
+	for {
+		tag, start, end, ok := nextTag(src, i)
+		if !ok {
+			return nil, 0, 0, errUnclosedBlocks
+		}
+		i = end
+
+		sigil, n := sectionName(tag)
+		if sigil == 0 {
+			continue
+		}
+		if sigil != '/' {
+			stack = append(stack, section{
+				sigil: sigil,
+				name:  n,
+				start: end,
+			})
+
+			continue
+		}
+
+		z := len(stack) - 1
+		if z < 0 {
+			if n != name {
+				return nil, 0, 0, errBlockMismatch
+			}
+
+			return sections, start, end, nil
+		}
+
+		se := stack[z]
+		if se.name != n {
+			return nil, 0, 0, errBlockMismatch
+		}
+		se.end = start
+
+		stack = stack[:z]
+		if z == 0 {
+			sections = append(sections, se)
+		}
+	}
+}
+
+// checkSyntax checks the tags of src without rendering it. It returns the
+// first tag that can not be parsed or section that is not balanced.
+func checkSyntax(src []byte) error {
+	_, err := scanSyntax(src)
+
+	return err
+}
+
+// Check checks the syntax of the template src without rendering it, as Set
+// does when a template is defined. The first problem found is returned as a
+// *SyntaxError.
+func Check(src []byte) error {
+	off, err := scanSyntax(src)
+	if err == nil {
+		return nil
+	}
+	if serr, ok := err.(*SyntaxError); ok {
+		return serr
+	}
+
+	return &SyntaxError{off, err.Error()}
+}
+
+// scanSyntax checks the syntax of src, returning the offset of the tag or
+// section that is not valid along with its error
+func scanSyntax(src []byte) (int, error) {
+	// opened is a section opened by the tag at pos
+	type opened struct {
+		name string
+		pos  int
+	}
+
+	var stack []opened
+
+	i := 0
+	for {
+		tag, start, end, ok := nextTag(src, i)
+		if !ok {
+			break
+		}
+		i = end
+
+		// offset of the tag's contents within src
+		pos := start + len(ldelim.Value())
+
+		if len(bytes.TrimSpace(tag)) == 0 {
+			return start, errEmptyTag
+		}
+		if kw, cond, j, ok := parseCond(tag); ok {
+			if _, err := parseExpr(cond, pos+j); err != nil {
+				return start, err
+			}
+			if kw == elseIfTag {
+				continue
+			}
+		}
+		if _, _, _, err := parsePartial(tag, pos); err != nil {
+			return start, err
+		}
+
+		sigil, name := sectionName(tag)
+		switch sigil {
+		case 0:
+			continue
+
+		case '/':
+			z := len(stack) - 1
+			if z < 0 {
+				return start, errNilBlock
+			}
+			if stack[z].name != name {
+				return start, errBlockMismatch
+			}
+			stack = stack[:z]
+
+		case '#', '^':
+			if _, _, err := parseMods(tag, pos); err != nil {
+				return start, err
+			}
+
+			fallthrough
+
+		default:
+			stack = append(stack, opened{name, start})
+		}
+	}
+	if z := len(stack) - 1; z >= 0 {
+		return stack[z].pos, errUnclosedBlocks
+	}
+
+	return 0, nil
+}
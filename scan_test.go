@@ -0,0 +1,117 @@
+package beard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_scanSection(t *testing.T) {
+	src := []byte(`a{{$title}}b{{#c}}{{$d}}{{/d}}{{/c}}{{/ title }}{{$e}}f{{/e}}{{/layout}}g`)
+
+	sections, start, end, err := scanSection(src, "layout")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	var exp = []section{
+		{'$', "title", 11, 36},
+		{'$', "e", 54, 55},
+	}
+	if !reflect.DeepEqual(exp, sections) {
+		t.Errorf("expected %v, got %v", exp, sections)
+	}
+	if got := string(src[start:end]); got != "{{/layout}}" {
+		t.Errorf("expected close tag, got %s", got)
+	}
+}
+
+func Test_scanSectionErrors(t *testing.T) {
+	for _, v := range []struct {
+		giv string
+		err error
+	}{
+		{`{{$a}}{{/a}}`, errUnclosedBlocks},
+		{`{{$a}}{{/b}}{{/layout}}`, errBlockMismatch},
+		{`{{/a}}`, errBlockMismatch},
+	} {
+		_, _, _, err := scanSection([]byte(v.giv), "layout")
+		if err != v.err {
+			t.Errorf("expected %s, got %v", v.err, err)
+		}
+	}
+}
+
+func Test_checkSyntax(t *testing.T) {
+	for _, v := range []struct {
+		giv string
+		err error
+	}{
+		{`<h1>{{a}}{{#b as k, v sort:k}}{{k}}{{else}}{{/b}}</h1>`, nil},
+		{`{{#if a > 1}}a{{else if b}}b{{/if}}{{>card item}}`, nil},
+		{`{{<layout}}{{$title}}a{{/title}}{{/layout}}`, nil},
+		{`{{#a}}`, errUnclosedBlocks},
+		{`{{#a}}{{/b}}`, errBlockMismatch},
+		{`{{/a}}`, errNilBlock},
+		{`{{ }}`, errEmptyTag},
+	} {
+		if err := checkSyntax([]byte(v.giv)); err != v.err {
+			t.Errorf("expected %v for %s, got %v", v.err, v.giv, err)
+		}
+	}
+}
+
+func Test_checkSyntaxSyntaxErrors(t *testing.T) {
+	for _, v := range []struct {
+		giv string
+		pos int
+	}{
+		{`<h1>{{#if a ==}}{{/if}}</h1>`, 14},
+		{`{{#a}}{{else if a = b}}{{/a}}`, 18},
+		{`{{#items limit:a}}{{/items}}`, 9},
+		{`{{>card a b}}`, 10},
+	} {
+		err := checkSyntax([]byte(v.giv))
+
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected a syntax error for %s, got %v", v.giv, err)
+
+			continue
+		}
+		if v.pos != serr.Offset {
+			t.Errorf("expected error at %d for %s, got %d",
+				v.pos, v.giv, serr.Offset)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, v := range []struct {
+		giv string
+		pos int
+		msg string
+	}{
+		{`<h1>{{a}}</h1>{{#b}}`, 14, "unclosed blocks"},
+		{`{{#a}}{{#b}}{{/a}}`, 12, "block mismatch"},
+		{`a{{/a}}`, 1, "nil block"},
+		{`ab{{ }}`, 2, "empty tag"},
+		{`{{#if a ==}}{{/if}}`, 10, "unexpected end of condition"},
+	} {
+		err := Check([]byte(v.giv))
+
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected a syntax error for %s, got %v", v.giv, err)
+
+			continue
+		}
+		if v.pos != serr.Offset || v.msg != serr.Msg {
+			t.Errorf("expected %q at %d for %s, got %q at %d",
+				v.msg, v.pos, v.giv, serr.Msg, serr.Offset)
+		}
+	}
+
+	if err := Check([]byte(`{{#a}}{{b}}{{/a}}`)); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+}
@@ -0,0 +1,151 @@
+package beard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Set is a collection of named templates. Partials and layouts are found
+// among the templates of the set before falling back on the set's PartialFunc.
+// A Set is safe for concurrent use.
+type Set struct {
+	partialFunc PartialFunc
+
+	mu        sync.RWMutex
+	templates map[string][]byte
+}
+
+// NewSet returns an empty Set, fn is used to find templates and partials which
+// are not part of the set and may be nil.
+func NewSet(fn PartialFunc) *Set {
+	return &Set{
+		partialFunc: fn,
+		templates:   make(map[string][]byte),
+	}
+}
+
+// Define adds the template src to the set as name, replacing any template
+// already defined as name. The template's syntax is checked once, when it is
+// defined.
+func (s *Set) Define(name, src string) error {
+	return s.define(name, []byte(src))
+}
+
+// Add reads the template from r and adds it to the set as name, see Define.
+func (s *Set) Add(name string, r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return s.define(name, b)
+}
+
+func (s *Set) define(name string, src []byte) error {
+	if err := checkSyntax(src); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	s.templates[name] = src
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Has returns true if name is defined in the set
+func (s *Set) Has(name string) bool {
+	_, ok := s.lookup(name)
+
+	return ok
+}
+
+func (s *Set) lookup(name string) ([]byte, bool) {
+	s.mu.RLock()
+	b, ok := s.templates[name]
+	s.mu.RUnlock()
+
+	return b, ok
+}
+
+// file returns the template name as a File. Templates which are not part of
+// the set are found with the set's PartialFunc, so templates loaded from an
+// fs.FS can be reloaded, see FSOptions. Their syntax is checked each time they
+// are loaded.
+func (s *Set) file(name string) (File, error) {
+	if b, ok := s.lookup(name); ok {
+		return bytes.NewReader(b), nil
+	}
+
+	r, err := s.partial(name)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("%w: %s", errTemplateNotDefined, name)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSyntax(b); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// partial is the PartialFunc of the templates rendered by the set
+func (s *Set) partial(name string) (io.Reader, error) {
+	if b, ok := s.lookup(name); ok {
+		return bytes.NewReader(b), nil
+	}
+	if s.partialFunc != nil {
+		return s.partialFunc(name)
+	}
+
+	return nil, fmt.Errorf("%w: %s", errTemplateNotDefined, name)
+}
+
+// Render renders the template name with d
+func (s *Set) Render(name string, d map[string]interface{}) (io.Reader, error) {
+	fi, err := s.file(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Render(fi, d, s.partial), nil
+}
+
+// RenderInLayouts renders the template name within the layouts, given from
+// the outermost to the innermost layout, see RenderInLayouts.
+func (s *Set) RenderInLayouts(
+	name string, d map[string]interface{}, layouts ...string) (io.Reader, error) {
+
+	fi, err := s.file(name)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(layouts))
+	for _, la := range layouts {
+		f, err := s.file(la)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	return RenderInLayouts(fi, d, s.partial, files...), nil
+}
+
+var errTemplateNotDefined = errors.New("template is not defined")
@@ -0,0 +1,179 @@
+package beard
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet(func(path string) (io.Reader, error) {
+		if path != "external" {
+			t.Errorf("invalid partial %s", path)
+		}
+
+		return bytes.NewReader([]byte(`<p>{{a}}</p>`)), nil
+	})
+
+	for name, src := range map[string]string{
+		"layout": `<html>{{>yield}}{{>yield scripts}}</html>`,
+		"page":   `{{#content_for scripts}}<script></script>{{/content_for}}{{>header}}{{>external}}`,
+		"header": `<h1>{{title}}</h1>`,
+	} {
+		if err := s.Define(name, src); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+	if err := s.Add("footer", strings.NewReader(`<footer></footer>`)); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	data := map[string]interface{}{
+		"a":     "b",
+		"title": "Hello",
+	}
+
+	r, err := s.Render("page", data)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	Asser{t}.
+		Given(a(r.(*Template))).
+		Then(bodyEquals(`<h1>Hello</h1><p>b</p>`)).
+		And(errorIs(nil))
+
+	r, err = s.RenderInLayouts("page", data, "layout")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	Asser{t}.
+		Given(a(r.(*Template))).
+		Then(bodyEquals(`<html><h1>Hello</h1><p>b</p><script></script></html>`)).
+		And(errorIs(nil))
+
+	if !s.Has("footer") || s.Has("missing") {
+		t.Errorf("expected footer and not missing to be defined")
+	}
+}
+
+func TestSetNotDefined(t *testing.T) {
+	s := NewSet(nil)
+	if err := s.Define("page", `{{>missing}}`); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if _, err := s.Render("missing", nil); !errors.Is(err, errTemplateNotDefined) {
+		t.Errorf("expected %s, got %v", errTemplateNotDefined, err)
+	}
+	if _, err := s.RenderInLayouts("page", nil, "missing"); !errors.Is(err, errTemplateNotDefined) {
+		t.Errorf("expected %s, got %v", errTemplateNotDefined, err)
+	}
+
+	r, err := s.Render("page", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, errTemplateNotDefined) {
+		t.Errorf("expected %s, got %v", errTemplateNotDefined, err)
+	}
+}
+
+func TestSetDefineChecksSyntax(t *testing.T) {
+	s := NewSet(nil)
+
+	err := s.Define("page", `{{#a}}{{/b}}`)
+	if !errors.Is(err, errBlockMismatch) {
+		t.Errorf("expected %s, got %v", errBlockMismatch, err)
+	}
+	if s.Has("page") {
+		t.Errorf("expected page not to be defined")
+	}
+}
+
+// closeReader is a ReadCloser which is not a File
+type closeReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeReader) Close() error {
+	r.closed = true
+
+	return nil
+}
+
+func TestSetLoadClosesAndChecksSyntax(t *testing.T) {
+	var readers []*closeReader
+
+	s := NewSet(func(path string) (io.Reader, error) {
+		src := `<p>{{a}}</p>`
+		if path == "bad" {
+			src = `{{#a}}{{/b}}`
+		}
+		r := &closeReader{Reader: strings.NewReader(src)}
+		readers = append(readers, r)
+
+		return r, nil
+	})
+
+	r, err := s.Render("good", map[string]interface{}{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(r); string(b) != `<p>b</p>` {
+		t.Errorf("expected <p>b</p>, got %s", b)
+	}
+
+	_, err = s.Render("bad", nil)
+	if !errors.Is(err, errBlockMismatch) {
+		t.Errorf("expected %s, got %v", errBlockMismatch, err)
+	}
+
+	for i, r := range readers {
+		if !r.closed {
+			t.Errorf("expected reader %d to be closed", i)
+		}
+	}
+}
+
+func TestSetConcurrentRender(t *testing.T) {
+	s := NewSet(nil)
+	if err := s.Define("item", `<li>{{.}}</li>`); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := s.Define("list", `<ul>{{#items}}{{>item}}{{/items}}</ul>`); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	var exp = `<ul><li>a</li><li>b</li></ul>`
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			r, err := s.Render("list", map[string]interface{}{
+				"items": []string{"a", "b"},
+			})
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+
+				return
+			}
+
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+			if got := string(b); exp != got {
+				t.Errorf("expected %s, got %s", exp, got)
+			}
+		}()
+	}
+	wg.Wait()
+}
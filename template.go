@@ -2,8 +2,11 @@ package beard
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"strings"
 )
 
@@ -54,11 +57,111 @@ type Template struct {
 
 	// parent is a reference to the parent Template for a partial
 	parent *Template
+
+	// comparators holds the user defined CompareFuncs for the sort modifier
+	comparators map[string]CompareFunc
+
+	// overrides holds the content of {{$block}}s overridden by an inheriting
+	// template
+	overrides map[string]*origin
+
+	// content holds the content captured by {{#content_for}}
+	content map[string][]byte
+
+	// layout holds the template being rendered within this template
+	layout *layout
+
+	// name is the name of the partial the template was rendered from
+	name string
+
+	// maxPartialDepth is the max depth partials can be nested
+	maxPartialDepth int
+
+	// locals are the arguments the partial was rendered with
+	locals *locals
+
+	// escCtx tracks the context of the HTML written when escaping values by
+	// their context
+	escCtx *escContext
+
+	// esc is the Escaper used to escape variables
+	esc Escaper
+
+	// escBuf is the buffer values are escaped into, it is taken from
+	// escapePool and returned once the template has been read
+	escBuf *[]byte
+
+	// limits counts the work done rendering the template against its Limits
+	limits *limiter
+
+	// ctx is the context of rendering the template
+	ctx context.Context
+
+	// sandbox restricts the partials the template can render
+	sandbox *sandbox
+
+	// tagOffset is the offset, within File, of the tag last read
+	tagOffset int
+
+	// failed is the template whose tag was being rendered when Read returned
+	// an error, the template itself or one of its partials
+	failed *Template
+
+	// from is the section of another template File is the content of, eg. a
+	// {{#content_for}}
+	from *origin
+
+	// captured is set when the template's output is captured to be yielded by
+	// a layout, eg. a {{#content_for}}
+	captured bool
+}
+
+// origin is the content of a section of te's File, at offset
+type origin struct {
+	src    []byte
+	te     *Template
+	offset int
 }
 
+// DefaultMaxPartialDepth is the max depth partials can be nested when a max
+// depth has not been set on the Template
+const DefaultMaxPartialDepth = 100
+
 var _ io.Reader = &Template{}
 
 func (t *Template) Read(p []byte) (int, error) {
+	n, err := t.read(p)
+	if err != nil && err != io.EOF && t.failed == nil {
+		t.failed = t
+	}
+
+	return n, err
+}
+
+// ErrorOffset returns the File and the offset within it of the tag being
+// rendered when Read returned an error. The tag may be within one of the
+// template's partials or, for a layout, the template it yields to. ok is false
+// when Read has not returned an error.
+func (t *Template) ErrorOffset() (f File, offset int, ok bool) {
+	te := t.failed
+	if te == nil {
+		return nil, 0, false
+	}
+
+	offset = te.tagOffset
+	for te.from != nil {
+		offset += te.from.offset
+		te = te.from.te
+	}
+
+	return te.File, offset, true
+}
+
+func (t *Template) read(p []byte) (int, error) {
+	if err := t.ctxErr(); err != nil {
+		return 0, err
+	}
+
 	lenp := len(p)
 	writ := 0
 
@@ -79,6 +182,9 @@ func (t *Template) Read(p []byte) (int, error) {
 	if lent := len(t.truncd); lent > 0 {
 		n, tr := t.flush(p)
 		t.truncd = tr
+		if err := t.countOutput(n); err != nil {
+			return n, err
+		}
 		// return if we've written out to the length of p. NOTE this should
 		// never write more than lenp
 		if n >= lenp {
@@ -107,7 +213,18 @@ func (t *Template) Read(p []byte) (int, error) {
 	}
 	t.buf = append(t.buf, p[writ:writ+n]...)
 
-	switch b, ma := t.delim().Match(t.buf); ma {
+	b, ma := t.delim().Match(t.buf)
+
+	// a tag is buffered until it is closed, as it may be split over several
+	// Reads. Whatever is left buffered at the end of the file is written as is.
+	switch {
+	case t.eof && ma == paMatch:
+		ma = noMatch
+	case !t.eof && ma == noMatch && t.delim() == rdelim:
+		ma = paMatch
+	}
+
+	switch ma {
 	case paMatch:
 		// NOTE: b is t.buf when partial match
 
@@ -129,6 +246,8 @@ func (t *Template) Read(p []byte) (int, error) {
 		// when we find a matching rdelim, {{..}} has been closed and we can now
 		// parse for the var value
 		if bytes.Equal(del, rdelim.Value()) {
+			t.tagOffset = t.cursor - lenb - len(ldelim.Value())
+
 			val, err = t.handleVar(tag)
 			if err != nil {
 				return writ, err
@@ -140,12 +259,14 @@ func (t *Template) Read(p []byte) (int, error) {
 			val = tag
 		}
 
-		// if we are in a current block and there is no data to render dont
-		// render any of the inner block content
-		_, bl := t.currentBlock()
-		if bl != nil && bl.Skip() {
+		// if we are in a block and there is no data to render dont render any
+		// of the inner block content
+		if t.skipping() {
 			return writ, nil
 		}
+		if c := t.escContext(); c != nil && !bytes.Equal(del, rdelim.Value()) {
+			c.write(val)
+		}
 
 		// combine truncated with current value and write
 		val = append(t.truncd, val...)
@@ -171,10 +292,31 @@ func (t *Template) Read(p []byte) (int, error) {
 		}
 		writ += j
 
+		if err := t.countOutput(j); err != nil {
+			return writ, err
+		}
+
 	default:
-		// if we have a buf, flush it. NOTE: buf at this point will always fit
-		// into p
+		// if we have a buf, flush it. NOTE: buf may have been read over several
+		// Reads and not fit into p, the rest is truncated for the next Read
 		if n := len(t.buf); n > 0 {
+			t.cursor += n
+
+			// text within a block that is not rendered is dropped
+			if t.skipping() {
+				t.buf = t.buf[:0]
+
+				break
+			}
+			if c := t.escContext(); c != nil {
+				c.write(t.buf)
+			}
+
+			if availn := lenp - writ; n > availn {
+				t.truncd = append([]byte(nil), t.buf[availn:]...)
+				n = availn
+			}
+
 			// p = append(p[:writ], t.buf[:n]...)
 			j := 0
 			i := writ
@@ -186,12 +328,20 @@ func (t *Template) Read(p []byte) (int, error) {
 			writ += j
 
 			t.buf = t.buf[:0]
-			t.cursor += writ
+
+			if err := t.countOutput(j); err != nil {
+				return writ, err
+			}
 		}
 	}
 
 	n = writ
 	if t.eof && len(t.buf) == 0 && len(t.truncd) == 0 {
+		if t.escBuf != nil {
+			putEscapeBuf(t.escBuf)
+			t.escBuf = nil
+		}
+
 		// check for unclosed blocks
 		var err = io.EOF
 		if len(t.blocks) > 0 {
@@ -209,6 +359,49 @@ func (t *Template) Partial(fn PartialFunc) {
 	t.partialFunc = fn
 }
 
+// ContextualEscape escapes values by the context of the HTML they are written
+// in, eg. as text, within an attribute, a URL, a <script> or a <style>.
+// Partials share the context of their parent template.
+func (t *Template) ContextualEscape() {
+	t.escCtx = &escContext{}
+}
+
+// escContext returns the escContext of the template, or of its closest parent
+// when escaping by context.
+func (t *Template) escContext() *escContext {
+	for te := t; te != nil; te = te.parent {
+		if te.escCtx != nil {
+			return te.escCtx
+		}
+	}
+
+	return nil
+}
+
+// Escaper sets the Escaper used to escape variables, variables are HTML escaped
+// by default. Partials use the Escaper of their parent template.
+func (t *Template) Escaper(e Escaper) {
+	t.esc = e
+}
+
+// getEscaper returns the Escaper of the template, or of its closest parent
+func (t *Template) getEscaper() Escaper {
+	for te := t; te != nil; te = te.parent {
+		if te.esc != nil {
+			return te.esc
+		}
+	}
+
+	return HTMLEscape
+}
+
+// MaxPartialDepth sets the max depth partials can be nested, this allows
+// partials to recursively render themselves while protecting against endless
+// recursion. Partials use the max depth of the root Template.
+func (t *Template) MaxPartialDepth(n int) {
+	t.maxPartialDepth = n
+}
+
 // flush writes truncated out to p. It writes up to the lesser of the two
 // lengths, p vs truncd. It returns any remaing bytes that couldn't be written
 // due to length constraints.
@@ -249,6 +442,37 @@ func (t *Template) swapDelim() {
 }
 
 func (t *Template) handleVar(v []byte) ([]byte, error) {
+	// offset of the tag within the File
+	offset := t.cursor - len(rdelim.Value()) - len(v)
+
+	if kw, cond, i, ok := parseCond(v); ok {
+		return nil, t.handleCond(kw, cond, offset+i)
+	}
+	if name, ok := parseKeyword(v, contentForTag); ok {
+		return nil, t.contentFor(name)
+	}
+	if name, ok := parseKeyword(v, yieldTag); ok && t.root().layout != nil {
+		return nil, t.yield(name)
+	}
+
+	if name, args, ok, err := parsePartial(v, offset); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, t.renderPartial(name, args)
+	}
+
+	var mods []modifier
+	if i := skipSpaces(v, 0); i < len(v) && (v[i] == '#' || v[i] == '^') {
+		var err error
+
+		v, mods, err = parseMods(v, offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var (
 		key, as = parseTag(v)
 
@@ -258,14 +482,22 @@ func (t *Template) handleVar(v []byte) ([]byte, error) {
 		return nil, errEmptyTag
 	}
 
+	if tag == elseTag || tag == elseTagAlt {
+		_, bl := t.currentBlock()
+		if bl == nil {
+			return nil, errElseOutsideBlock
+		}
+
+		return nil, bl.Else()
+	}
+
 	esc := true
 
 	switch tag[0] {
 	case '#', '^':
-		bl := t.newBlock(tag, t.cursor, as...)
-		// bl := t.newBlock(tag, t.cursor-(len(v)+len(rdelim)+len(ldelim)))
-		if bl == nil {
-			// TODO not sure how this can actually happen...?
+		_, err := t.newBlock(tag, t.cursor, mods, as...)
+		if err != nil {
+			return nil, err
 		}
 
 		return v[:0], nil
@@ -278,76 +510,420 @@ func (t *Template) handleVar(v []byte) ([]byte, error) {
 		if bl.tag[1:] != tag[1:] {
 			return nil, errBlockMismatch
 		}
+		if err := t.countIteration(); err != nil {
+			return nil, err
+		}
+		if err := t.ctxErr(); err != nil {
+			return nil, err
+		}
 		if bl.Increment(); bl.Finished() {
 			t.popBlock()
 
 			return nil, nil
 		}
 
-		// reset the buffer and move the cursor to the block's cursor
-		// location
-		t.buf = t.buf[:0]
-		t.cursor = bl.cursor
-
-		// set the File's cusror to be read at on the next Read
-		_, err := t.File.Seek(int64(t.cursor), 0)
-
-		return nil, err
+		return nil, t.seek(bl.cursor)
 
 	case '&':
 		tag = tag[1:]
 		esc = false
 
-	case '>':
-		r, err := t.newPartial(tag[1:])
-		if err != nil {
-			return nil, err
-		}
-		t.partial = r
+	case '<':
+		return nil, t.inherit(tag[1:])
 
-		return nil, nil
+	case '$':
+		return nil, t.override(tag)
+
+	case '>':
+		return nil, t.renderPartial(tag[1:], nil)
 	}
 
 	// TODO how to handle/detect unclosed blocks earlier than at the end of the
 	// read cycles.
 
-	val := t.getValue(tag)
-	if esc {
-		val = escapeBytes(val)
+	d, err := t.call(t.lookup(tag))
+	if err != nil {
+		return nil, err
+	}
+	c := t.escContext()
+	if !esc {
+		// raw values are written to the context as they are output, so it
+		// follows what was actually written
+		b := dataBytes(d)
+		if c != nil {
+			c.write(b)
+		}
+
+		return b, nil
+	}
+
+	if c != nil {
+		return c.escape(d), nil
 	}
 
-	return val, nil
+	e := t.getEscaper()
+	if _, ok := e.(htmlEscaper); ok && trusted(d) {
+		return d.Bytes(), nil
+	}
+
+	// the escaped value is copied out on Read, so the buffer can be reused by
+	// the next variable
+	if t.escBuf == nil {
+		t.escBuf = escapePool.Get().(*[]byte)
+	}
+	b := e.Escape((*t.escBuf)[:0], dataBytes(d))
+	*t.escBuf = b[:0]
+
+	return b, nil
+}
+
+// seek resets the buffer and moves the cursor, and File's cursor, to c
+func (t *Template) seek(c int) error {
+	t.buf = t.buf[:0]
+	t.cursor = c
+	t.eof = false
+
+	// set the File's cusror to be read at on the next Read
+	_, err := t.File.Seek(int64(c), 0)
+
+	return err
+}
+
+// skipSection moves past the section name, returning the content of the
+// section and the sections directly within it.
+func (t *Template) skipSection(name string) ([]byte, []section, error) {
+	if _, err := t.File.Seek(int64(t.cursor), 0); err != nil {
+		return nil, nil, err
+	}
+	src, err := ioutil.ReadAll(t.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	sections, start, end, err := scanSection(src, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src[:start], sections, t.seek(t.cursor + end)
+}
+
+// contentFor renders the content of {{#content_for name}} to be output by a
+// layout with {{>yield name}}. Content for the same name is appended.
+func (t *Template) contentFor(name string) error {
+	if name == "" {
+		return errContentForName
+	}
+
+	start := t.cursor
+
+	src, _, err := t.skipSection(contentForTag[1:])
+	if err != nil {
+		return err
+	}
+	if t.skipping() {
+		return nil
+	}
+
+	te := &Template{
+		File:      bytes.NewReader(src),
+		Data:      t.Data,
+		overrides: t.overrides,
+		parent:    t,
+		from:      &origin{src: src, te: t, offset: start},
+		captured:  true,
+	}
+	te.Partial(t.partialFunc)
+
+	// captured content is written elsewhere, so it starts in its own context
+	if t.escContext() != nil {
+		te.ContextualEscape()
+	}
+
+	b, err := ioutil.ReadAll(te)
+	if err != nil {
+		t.failed = te.failed
+
+		return err
+	}
+
+	ro := t.root()
+	if ro.content == nil {
+		ro.content = make(map[string][]byte)
+	}
+	ro.content[name] = append(ro.content[name], b...)
+
+	return nil
+}
+
+// yield outputs the content of the template rendered within the layout,
+// {{>yield}}, or the named content captured by it, {{>yield name}}.
+func (t *Template) yield(name string) error {
+	if t.skipping() {
+		return nil
+	}
+
+	ro := t.root()
+
+	if te, ok := ro.layout.inner.(*Template); ok {
+		// the inner template starts in the context of its first yield
+		if c := t.escContext(); c != nil && te.escCtx == nil {
+			cp := *c
+			cp.tag = append([]byte(nil), c.tag...)
+			cp.attrName = append([]byte(nil), c.attrName...)
+			te.escCtx = &cp
+		}
+		if te.esc == nil {
+			te.esc = t.getEscaper()
+		}
+		if te.limits == nil {
+			te.limits = t.limiter()
+		}
+		te.captured = true
+		if te.ctx == nil {
+			te.ctx = t.context()
+		}
+		if te.sandbox == nil {
+			te.sandbox = t.getSandbox()
+		}
+	}
+
+	r, err := ro.layout.yield(name)
+	if err != nil {
+		if te, ok := ro.layout.inner.(*Template); ok {
+			t.failed = te.failed
+		}
+
+		return err
+	}
+	if t.limiter() != nil {
+		r = &outputReader{Reader: r, t: t}
+	}
+	t.partial = r
+
+	return nil
+}
+
+// inherit renders the parent template, {{<parent}}, using the {{$block}}s
+// defined within it to override the parent's blocks. Any other content within
+// {{<parent}} is ignored.
+func (t *Template) inherit(name string) error {
+	start := t.cursor
+
+	src, sections, err := t.skipSection(name)
+	if err != nil {
+		return err
+	}
+	if t.skipping() {
+		return nil
+	}
+
+	// overrides inherited from a child take precedence over our own
+	overrides := make(map[string]*origin, len(sections)+len(t.overrides))
+	for _, se := range sections {
+		if se.sigil == '$' {
+			overrides[se.name] = &origin{
+				src:    src[se.start:se.end],
+				te:     t,
+				offset: start + se.start,
+			}
+		}
+	}
+	for k, v := range t.overrides {
+		overrides[k] = v
+	}
+
+	r, err := t.newPartial(name)
+	if err != nil {
+		return err
+	}
+	if te, ok := r.(*Template); ok {
+		te.overrides = overrides
+	}
+	t.partial = r
+
+	return nil
+}
+
+// override handles a {{$block}}. Its content is rendered unless it has been
+// overridden, in which case the overriding content is rendered in its place.
+func (t *Template) override(tag string) error {
+	name := tag[1:]
+
+	o, ok := t.overrides[name]
+	if !ok {
+		return t.pushBlock(newCondBlock(tag, t.cursor, true))
+	}
+
+	if _, _, err := t.skipSection(name); err != nil {
+		return err
+	}
+	if t.skipping() {
+		return nil
+	}
+
+	te := &Template{
+		File:      bytes.NewReader(o.src),
+		Data:      t.Data,
+		overrides: t.overrides,
+		parent:    t,
+		from:      o,
+	}
+	te.Partial(t.partialFunc)
+
+	t.partial = te
+
+	return nil
+}
+
+// handleCond handles conditional tags, {{#if cond}} and {{else if cond}}
+func (t *Template) handleCond(kw string, cond []byte, offset int) error {
+	x, err := parseExpr(cond, offset)
+	if err != nil {
+		return err
+	}
+
+	if kw == ifTag {
+		if bl := t.findBlock(kw, t.cursor); bl != nil {
+			return nil
+		}
+
+		ok, err := evalTruthy(x, t)
+		if err != nil {
+			return err
+		}
+
+		return t.pushBlock(newCondBlock(kw, t.cursor, ok))
+	}
+
+	_, bl := t.currentBlock()
+	if bl == nil {
+		return errElseOutsideBlock
+	}
+
+	return bl.ElseIf(func() (bool, error) {
+		return evalTruthy(x, t)
+	})
 }
 
 // getValue looks up the value within Data map. It will iterrate *up* the blocks
 // before looking at the root Data field itself.
 func (t *Template) getValue(k string) []byte {
+	if v := t.lookup(k); v != nil {
+		return v.Bytes()
+	}
+
+	return nil
+}
+
+// lookup finds the Data for the path k. Paths prefixed with @root. are looked
+// up directly on the root Data, unless within an isolated partial, while each
+// ../ prefix skips a scope, starting from the current block.
+func (t *Template) lookup(k string) *Data {
+	if strings.HasPrefix(k, rootPrefix) {
+		if t.isolated() {
+			return nil
+		}
+		if ro := t.root(); ro.Data != nil {
+			return ro.Data.Get(k[len(rootPrefix):])
+		}
+
+		return nil
+	}
+
+	up := 0
+	for strings.HasPrefix(k, parentPrefix) {
+		k = k[len(parentPrefix):]
+		up++
+	}
+	if up > 0 && k == "" {
+		k = "."
+	}
+
+	return t.lookupScope(k, up, up > 0)
+}
+
+// lookupScope walks *up* the scopes looking for k. When explicit, the lookup
+// will only happen on the scope reached after skipping up scopes, otherwise
+// the closest match is used.
+func (t *Template) lookupScope(k string, up int, explicit bool) *Data {
 	z := len(t.blocks)
 	for ; z > 0; z-- {
 		bl := t.blocks[z-1]
 		if bl.Skip() {
 			return nil
 		}
-		if bl.Inverted() {
+		if !bl.Scoped() {
 			continue
 		}
-		if v := bl.Data().Get(k); v != nil {
-			return v.Bytes()
+		if up > 0 {
+			up--
+
+			continue
+		}
+		if v := bl.Data().Get(k); v != nil || explicit {
+			return v
+		}
+	}
+	if l := t.locals; l != nil {
+		if up > 0 {
+			up--
+		} else if v := l.get(k); v != nil || explicit {
+			return v
+		}
+		if l.isolated {
+			return nil
 		}
 	}
 	if t.parent != nil {
-		return t.parent.getValue(k)
+		return t.parent.lookupScope(k, up, explicit)
+	}
+	if up > 0 {
+		return nil
 	}
 
-	// . never looks up outside of a block
-	if k == "." {
+	// . never looks up outside of a block, unless explicitly asked for
+	if (k == "." && !explicit) || t.Data == nil {
 		return nil
 	}
-	if v := t.Data.Get(k); v != nil {
-		return v.Bytes()
+
+	return t.Data.Get(k)
+}
+
+// isolated returns true if the template is, or is within, an isolated partial
+func (t *Template) isolated() bool {
+	for te := t; te != nil; te = te.parent {
+		if te.locals != nil && te.locals.isolated {
+			return true
+		}
 	}
 
-	return nil
+	return false
+}
+
+// root returns the top most Template in the partial chain
+func (t *Template) root() *Template {
+	for t.parent != nil {
+		t = t.parent
+	}
+
+	return t
+}
+
+const (
+	rootPrefix    = "@root."
+	parentPrefix  = "../"
+	dynamicPrefix = "*"
+)
+
+// skipping checks to see if any of the current blocks are being skipped
+func (t *Template) skipping() bool {
+	for _, bl := range t.blocks {
+		if bl.Skip() {
+			return true
+		}
+	}
+
+	return false
 }
 
 // cleanSpaces removes all spaces by shifting over the spaces allow us to return
@@ -371,28 +947,88 @@ func cleanSpaces(b []byte) []byte {
 	return b[:j]
 }
 
-func (t *Template) newBlock(tag string, c int, as ...string) *block {
+func (t *Template) newBlock(
+	tag string, c int, mods []modifier, as ...string) (*block, error) {
+
 	bl := t.findBlock(tag, c)
 	if bl != nil {
-		return bl
+		return bl, nil
 	}
 
-	var (
-		data *Data
-
-		_, cb = t.currentBlock()
-	)
-	if cb != nil && len(cb.as) > 0 {
-		// if the current block is As'd, we need to look at this data block for
-		// lookup, as the var will be the 'as' value and not a key in the
-		// original data block
-		data = cb.Data()
-	} else {
-		data = t.Data
+	data, err := t.call(t.blockData(tag[1:]))
+	if err != nil {
+		return nil, err
+	}
+	data, err = t.applyMods(data, mods, as...)
+	if err != nil {
+		return nil, err
 	}
-	bl = newBlock(tag, c, data.Get(tag[1:]))
+
+	bl = newBlock(tag, c, data)
 	bl.As(as...)
 
+	if err := t.pushBlock(bl); err != nil {
+		return nil, err
+	}
+
+	return bl, nil
+}
+
+// blockData finds the Data for a block's path. Blocks walk up the scopes like
+// variables do, except a partial's blocks won't look past the scope the
+// partial was rendered in, so a recursive partial's leaf won't find its
+// parent's data, eg. {{#kids}}{{>node}}{{/kids}}. ../ and @root. paths are
+// looked up as they are for variables.
+func (t *Template) blockData(k string) *Data {
+	if strings.HasPrefix(k, rootPrefix) || strings.HasPrefix(k, parentPrefix) {
+		return t.lookup(k)
+	}
+
+	return t.lookupBlock(k, false)
+}
+
+// lookupBlock looks for k within the template's scopes, then the current scope
+// of the template it is a partial of. When current, only the closest scope is
+// looked at.
+func (t *Template) lookupBlock(k string, current bool) *Data {
+	z := len(t.blocks)
+	for ; z > 0; z-- {
+		bl := t.blocks[z-1]
+		if bl.Skip() {
+			return nil
+		}
+		if !bl.Scoped() {
+			continue
+		}
+		if v := bl.Data().Get(k); v != nil || current {
+			return v
+		}
+	}
+	if l := t.locals; l != nil {
+		if v := l.get(k); v != nil {
+			return v
+		}
+		if l.isolated {
+			return nil
+		}
+	}
+	if t.parent != nil {
+		return t.parent.lookupBlock(k, true)
+	}
+	if t.Data == nil {
+		return nil
+	}
+
+	return t.Data.Get(k)
+}
+
+// pushBlock adds a block to the end of the blocks list, making it the current
+// block. The block must not nest deeper than the template's max depth.
+func (t *Template) pushBlock(bl *block) error {
+	if err := t.checkDepth(); err != nil {
+		return err
+	}
+
 	// lazy alloc
 	if t.blocks == nil {
 		t.blocks = make([]*block, 0, 32)
@@ -400,7 +1036,7 @@ func (t *Template) newBlock(tag string, c int, as ...string) *block {
 
 	t.blocks = append(t.blocks, bl)
 
-	return bl
+	return nil
 }
 
 // findBlock finds a block by it's name (tag) and cursot.
@@ -450,10 +1086,57 @@ func (t *Template) popBlock() *block {
 	return bl
 }
 
+// renderPartial sets the partial name to be read next, rendering it with args
+// when given.
+func (t *Template) renderPartial(name string, args *partialArgs) error {
+	if t.skipping() {
+		return nil
+	}
+
+	if strings.HasPrefix(name, dynamicPrefix) {
+		// dynamic names are resolved from the data, eg. {{>*item.kind}}, a name
+		// that resolves to nothing renders nothing
+		name = string(t.getValue(name[len(dynamicPrefix):]))
+		if name == "" {
+			return nil
+		}
+	}
+
+	r, err := t.newPartial(name)
+	if err != nil {
+		return err
+	}
+	if te, ok := r.(*Template); ok && args != nil {
+		te.locals, err = args.locals(t)
+		if err != nil {
+			closePartial(r)
+
+			return err
+		}
+	}
+	t.partial = r
+
+	return nil
+}
+
 func (t *Template) newPartial(path string) (io.Reader, error) {
 	if t.partialFunc == nil {
 		return nil, errInvalidPartialFunc
 	}
+	if err := t.checkPartialDepth(path); err != nil {
+		return nil, err
+	}
+	if sb := t.getSandbox(); sb != nil {
+		if err := sb.checkPartial(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.countPartial(); err != nil {
+		return nil, err
+	}
+	if err := t.checkDepth(); err != nil {
+		return nil, err
+	}
 
 	r, err := t.partialFunc(path)
 	if err != nil {
@@ -468,6 +1151,7 @@ func (t *Template) newPartial(path string) (io.Reader, error) {
 		te := &Template{
 			File: f,
 			Data: t.Data,
+			name: path,
 		}
 		te.Partial(t.partialFunc)
 
@@ -482,6 +1166,35 @@ func (t *Template) newPartial(path string) (io.Reader, error) {
 	return r, nil
 }
 
+// checkPartialDepth checks that rendering the partial name will not nest
+// partials deeper than the max partial depth
+func (t *Template) checkPartialDepth(name string) error {
+	var chain []string
+	for te := t; te != nil; te = te.parent {
+		if te.name != "" {
+			chain = append(chain, te.name)
+		}
+	}
+
+	max := t.root().maxPartialDepth
+	if max <= 0 {
+		max = DefaultMaxPartialDepth
+	}
+	if len(chain) < max {
+		return nil
+	}
+
+	// reverse to order the chain from the outermost partial
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	return &PartialDepthError{
+		Max:   max,
+		Chain: append(chain, name),
+	}
+}
+
 func (t *Template) readPartial(p []byte) (int, error) {
 	n, err := t.partial.Read(p)
 	if err == nil {
@@ -492,6 +1205,10 @@ func (t *Template) readPartial(p []byte) (int, error) {
 	defer closePartial(t.partial)
 
 	if err != io.EOF {
+		if te, ok := t.partial.(*Template); ok {
+			t.failed = te.failed
+		}
+
 		return n, err
 	}
 
@@ -501,6 +1218,89 @@ func (t *Template) readPartial(p []byte) (int, error) {
 
 }
 
+const (
+	elseTag    = "else"
+	elseTagAlt = ":else"
+	ifTag      = "#if"
+	elseIfTag  = "else if"
+
+	contentForTag = "#content_for"
+	yieldTag      = ">yield"
+)
+
+// parseKeyword parses tags of a keyword followed by an optional argument, eg.
+// #content_for scripts, returning the argument.
+func parseKeyword(tag []byte, kw string) (string, bool) {
+	i := skipSpaces(tag, 0)
+	if i == len(tag) || tag[i] != kw[0] {
+		return "", false
+	}
+	i = skipSpaces(tag, i+1)
+	if !hasWord(tag[i:], kw[1:]) {
+		return "", false
+	}
+	i += len(kw) - 1
+
+	// the keyword must be followed by a space or the end of the tag
+	if i < len(tag) && tag[i] != ' ' {
+		return "", false
+	}
+
+	return string(bytes.TrimSpace(tag[i:])), true
+}
+
+// parseCond parses conditional tags, eg. #if a == b or else if a. It returns
+// the keyword, the condition and the offset of the condition within the tag.
+func parseCond(tag []byte) (string, []byte, int, bool) {
+	i := skipSpaces(tag, 0)
+
+	var kw string
+
+	switch {
+	case hasWord(tag[i:], "#"):
+		i = skipSpaces(tag, i+1)
+		kw = ifTag
+	case hasWord(tag[i:], elseTag):
+		i += len(elseTag)
+		kw = elseIfTag
+	case hasWord(tag[i:], elseTagAlt):
+		i += len(elseTagAlt)
+		kw = elseIfTag
+	default:
+		return "", nil, 0, false
+	}
+	if kw == elseIfTag {
+		j := skipSpaces(tag, i)
+		if j == i {
+			return "", nil, 0, false
+		}
+		i = j
+	}
+	if !hasWord(tag[i:], "if") {
+		return "", nil, 0, false
+	}
+	i += len("if")
+
+	// the keyword must be followed by a space or the end of the tag
+	if i < len(tag) && tag[i] != ' ' {
+		return "", nil, 0, false
+	}
+
+	return kw, tag[i:], i, true
+}
+
+func hasWord(b []byte, w string) bool {
+	return bytes.HasPrefix(b, []byte(w))
+}
+
+func skipSpaces(b []byte, i int) int {
+	for i < len(b) && b[i] == ' ' {
+		i++
+	}
+
+	return i
+}
+
 var as_delim = []byte(" as ")
 
 func parseTag(tag []byte) ([]byte, []string) {
@@ -542,10 +1342,38 @@ func closePartial(par interface{}) {
 	c.Close()
 }
 
+// PartialDepthError is returned when partials are nested deeper than the max
+// partial depth. Chain holds the names of the nested partials, starting from
+// the outermost partial.
+type PartialDepthError struct {
+	Max   int
+	Chain []string
+}
+
+func (e *PartialDepthError) Error() string {
+	chain := e.Chain
+
+	// name the cycle, the partials between the last partial and its previous
+	// occurrence
+	z := len(chain) - 1
+	for i := z - 1; i > -1; i-- {
+		if chain[i] == chain[z] {
+			return fmt.Sprintf("partial depth of %d exceeded, cycle %s",
+				e.Max, strings.Join(chain[i:], " > "))
+		}
+	}
+
+	return fmt.Sprintf("partial depth of %d exceeded, %s",
+		e.Max, strings.Join(chain, " > "))
+}
+
 var (
 	errInvalidPartialFunc = errors.New("partial func is undefined")
 	errUnclosedBlocks     = errors.New("unclosed blocks")
 	errNilBlock           = errors.New("nil block")
 	errBlockMismatch      = errors.New("block mismatch")
 	errEmptyTag           = errors.New("empty tag")
+	errElseOutsideBlock   = errors.New("else outside of block")
+	errDuplicateElse      = errors.New("duplicate else")
+	errContentForName     = errors.New("content_for requires a name")
 )
@@ -2,7 +2,12 @@ package beard
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"html/template"
 	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
 )
 
@@ -69,6 +74,133 @@ func TestTemplateBufTruncdOut(t *testing.T) {
 	}
 }
 
+func TestTemplateTagsSplitOverReads(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 5} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(`<p>{a} {{name}}</p>{`)),
+			Data: &Data{Value: map[string]interface{}{
+				"name": "Batman",
+			}},
+		}
+
+		var (
+			out []byte
+			buf = make([]byte, size)
+		)
+		for {
+			n, err := tmpl.Read(buf)
+			out = append(out, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if exp, got := `<p>{a} Batman</p>{`, string(out); exp != got {
+			t.Errorf("expected %s, got %s", exp, got)
+		}
+	}
+}
+
+func TestTemplateBlocksOverSmallReads(t *testing.T) {
+	for _, size := range []int{1, 3, 4, 7} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(
+				`abcdefghij {{x}} klmnopqrstuvwxyz {{#items}}[{{.}}]{{/items}} tail`)),
+			Data: &Data{Value: map[string]interface{}{
+				"x":     "X",
+				"items": []interface{}{1, 2, 3},
+			}},
+		}
+
+		var (
+			out []byte
+			buf = make([]byte, size)
+		)
+		for {
+			n, err := tmpl.Read(buf)
+			out = append(out, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		exp := `abcdefghij X klmnopqrstuvwxyz [1][2][3] tail`
+		if got := string(out); exp != got {
+			t.Errorf("expected %s with %d byte reads, got %s", exp, size, got)
+		}
+	}
+}
+
+// readSized reads r in full with Reads of size bytes
+func readSized(r io.Reader, size int) (string, error) {
+	var (
+		out []byte
+		buf = make([]byte, size)
+	)
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return string(out), nil
+		}
+		if err != nil {
+			return string(out), err
+		}
+	}
+}
+
+func TestTemplateOverEveryReadSize(t *testing.T) {
+	for _, v := range []struct {
+		html string
+		data map[string]interface{}
+	}{
+		{`<script>function f(){ return {{name}}; }</script>`,
+			map[string]interface{}{"name": "a"}},
+		{`<p>{{#missing}}hidden{{/missing}}{{#admin}}SECRET{{/admin}}</p>`,
+			map[string]interface{}{"admin": false}},
+		{`<ul>{{#items}}<li>{{.}}</li>{{else}}none{{/items}}</ul>`,
+			map[string]interface{}{"items": []string{"a", "b"}}},
+		{`<h1>{{#words}}({{.}}){{else}}{{title}}{{/words}}</h1>`,
+			map[string]interface{}{"title": "Hola Mundo!"}},
+		{`<h1>{{^words}}Hola Mundo!{{:else}}{{#words}}({{.}}){{/words}}{{/words}}</h1>`,
+			map[string]interface{}{"words": []string{"a", "b", "c"}}},
+		{`{{#if status == "active" && count > 0}}{{name}} has {{count}}{{else if !count}}{{name}} has none{{else}}{{name}} is {{status}}{{/if}}`,
+			map[string]interface{}{"name": "a", "status": "inactive", "count": 2}},
+		{`{{#items}}{{#if price >= 10}}({{name}}){{else}}-{{/if}}{{/items}}`,
+			map[string]interface{}{"items": []map[string]interface{}{
+				{"name": "a", "price": 12.5},
+				{"name": "b", "price": 3},
+			}}},
+	} {
+		exp, err := readSized(&Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: v.data},
+		}, 4096)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for size := 1; size <= len(v.html); size++ {
+			got, err := readSized(&Template{
+				File: bytes.NewReader([]byte(v.html)),
+				Data: &Data{Value: v.data},
+			}, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != exp {
+				t.Errorf("expected %s with %d byte reads, got %s", exp, size, got)
+			}
+		}
+	}
+}
+
 func TestTemplateBasicVariables(t *testing.T) {
 	html := `<h1>{{a}} {{b}}{{c}}</h1>`
 	data := map[string]interface{}{
@@ -291,6 +423,102 @@ func TestTemplateOutsideOfBlockVarUsesClosestVar(t *testing.T) {
 		And(errorIs(nil))
 }
 
+func TestTemplateParentScopePath(t *testing.T) {
+	html := `{{#people}}{{name}}:{{../name}}:{{@root.name}},{{/people}}`
+	data := map[string]interface{}{
+		"name": "Gotham",
+		"people": []map[string]interface{}{
+			{"name": "Batman"},
+			{"name": "Robin"},
+		},
+	}
+
+	var exp = `Batman:Gotham:Gotham,Robin:Gotham:Gotham,`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateParentScopePathIsExplicit(t *testing.T) {
+	html := `{{#a}}{{#b}}({{../name}})({{../../name}})({{../../../name}}){{/b}}{{/a}}`
+	data := map[string]interface{}{
+		"name": "root",
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"name": "b",
+			},
+		},
+	}
+
+	// a does not have a name and ../name should not traverse further up
+	var exp = `()(root)()`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateParentScopePathThroughPartials(t *testing.T) {
+	html := `{{#people}}{{>person}}{{/people}}`
+	data := map[string]interface{}{
+		"name": "Gotham",
+		"people": []map[string]interface{}{
+			{"name": "Batman", "tags": []string{"a", "b"}},
+		},
+	}
+
+	var exp = `Batman(a:Batman:Gotham)(b:Batman:Gotham)`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		return bytes.NewReader([]byte(
+			`{{name}}{{#tags}}({{.}}:{{../name}}:{{@root.name}}){{/tags}}`)), nil
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateParentScopeBlock(t *testing.T) {
+	html := `{{#a}}{{#../words}}{{.}}{{/../words}}{{#@root.words}}{{.}}{{/@root.words}}{{/a}}`
+	data := map[string]interface{}{
+		"words": []string{"x", "y"},
+		"a": map[string]interface{}{
+			"words": []string{"z"},
+		},
+	}
+
+	var exp = `xyxy`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
 func TestTemplateEscapesStrings(t *testing.T) {
 	html := `<code>{{code}}</code>`
 	data := map[string]interface{}{
@@ -506,15 +734,18 @@ func TestTemplatePartialInBlock(t *testing.T) {
 		And(errorIs(nil))
 }
 
-func TestTemplateVarsContainSpaces(t *testing.T) {
-	html := `<h1>{{a }}{{ > b }}{{ e}}</h1>`
+func TestTemplateDynamicPartial(t *testing.T) {
+	html := `<ul>{{#items}}<li>{{> *kind}}</li>{{/items}}</ul>`
 	data := map[string]interface{}{
-		"a": "Hello",
-		"d": "World",
-		"e": "!",
+		"items": []interface{}{
+			map[string]interface{}{"kind": "post", "title": "Hello"},
+			map[string]interface{}{"kind": "photo", "src": "a.png"},
+			map[string]interface{}{"kind": "", "title": "Empty"},
+			map[string]interface{}{"title": "Missing"},
+		},
 	}
 
-	var exp = `<h1>Hello World!</h1>`
+	var exp = `<ul><li><h2>Hello</h2></li><li><img src="a.png"></li><li></li><li></li></ul>`
 
 	tmpl := &Template{
 		File: bytes.NewReader([]byte(html)),
@@ -523,13 +754,13 @@ func TestTemplateVarsContainSpaces(t *testing.T) {
 	tmpl.Partial(func(path string) (io.Reader, error) {
 		var p []byte
 		switch path {
-		case "b":
-			p = []byte(` {{> c}}`)
-		case "c":
-			p = []byte(`{{d}}`)
+		case "post":
+			p = []byte(`<h2>{{title}}</h2>`)
+		case "photo":
+			p = []byte(`<img src="{{src}}">`)
 
 		default:
-			t.Error("invalid partial %s", path)
+			t.Errorf("invalid partial %s", path)
 		}
 
 		return bytes.NewReader(p), nil
@@ -541,48 +772,66 @@ func TestTemplateVarsContainSpaces(t *testing.T) {
 		And(errorIs(nil))
 }
 
-func TestTemplateEmptyNilUnexistBlockData(t *testing.T) {
-	html := `<h1>{{#words}}({{.}}){{/words}}</h1>`
+func TestTemplatePartialArgs(t *testing.T) {
+	partials := map[string]string{
+		"button": `<button class="{{kind}}">{{label}}</button>`,
+		"card":   `<div>{{name}}{{#tags}} {{.}}{{/tags}} {{title}}</div>`,
+		"item":   `<li>{{.}}{{title}}</li>`,
+		"root":   `<b>{{@root.title}}{{#if @root.title}}!{{/if}}</b>`,
+		"wrap":   `<i>{{>root}}</i>`,
+	}
 
-	for _, v := range []interface{}{
-		map[string]interface{}{
-			"words": []interface{}{},
-		},
-		map[string]interface{}{
-			"words": nil,
+	data := map[string]interface{}{
+		"title": "Page",
+		"theme": map[string]interface{}{
+			"primary": "blue",
 		},
-		map[string]interface{}{
-			"badwords": []string{"foo"},
+		"users": []interface{}{
+			map[string]interface{}{"name": "a", "tags": []string{"x", "y"}},
+			map[string]interface{}{"name": "b"},
 		},
-	} {
-		var exp = `<h1></h1>`
-
-		tmpl := &Template{
-			File: bytes.NewReader([]byte(html)),
-			Data: &Data{Value: v},
-		}
-
-		Asser{t}.
-			Given(a(tmpl)).
-			Then(bodyEquals(exp)).
-			And(errorIs(nil))
+		"words": []string{"c", "d"},
 	}
-}
-
-func TestTemplateInvertedBlock(t *testing.T) {
-	html := `<h1>{{#words}}({{.}}){{/words}}{{^words}}Hola Mundo!{{/words}}</h1>`
 
 	for _, v := range []struct {
-		data map[string]interface{}
-		exp  string
+		giv, exp string
 	}{
-		{map[string]interface{}{"words": []string{"a", "b", "c"}}, `<h1>(a)(b)(c)</h1>`},
-		{map[string]interface{}{}, `<h1>Hola Mundo!</h1>`},
+		{
+			`{{>button label="Save" kind=theme.primary}}`,
+			`<button class="blue">Save</button>`,
+		},
+		{
+			`{{#users}}{{>card .}}{{/users}}`,
+			`<div>a x y Page</div><div>b Page</div>`,
+		},
+		{
+			`{{#users}}{{>card . isolated}}{{/users}}`,
+			`<div>a x y </div><div>b </div>`,
+		},
+		{
+			`{{>card users title="Users"}}`,
+			`<div> Users</div>`,
+		},
+		{
+			`{{#words}}{{> item . }}{{/words}}`,
+			`<li>cPage</li><li>dPage</li>`,
+		},
+		{
+			`{{>root}}{{>root isolated}}{{>wrap isolated}}`,
+			`<b>Page!</b><b></b><i><b></b></i>`,
+		},
+		{
+			`{{>button label=title kind=nil}}{{>button kind="red"}}`,
+			`<button class="">Page</button><button class="red"></button>`,
+		},
 	} {
 		tmpl := &Template{
-			File: bytes.NewReader([]byte(html)),
-			Data: &Data{Value: v.data},
+			File: bytes.NewReader([]byte(v.giv)),
+			Data: &Data{Value: data},
 		}
+		tmpl.Partial(func(path string) (io.Reader, error) {
+			return bytes.NewReader([]byte(partials[path])), nil
+		})
 
 		Asser{t}.
 			Given(a(tmpl)).
@@ -591,23 +840,23 @@ func TestTemplateInvertedBlock(t *testing.T) {
 	}
 }
 
-func TestTemplateInvertedBlockDoesNotTraverseUp(t *testing.T) {
-	html := `<h1>{{#many.words}}({{.}}){{/many.words}}{{^many.words}}Hola Mundo!{{/many.words}}</h1>`
+func TestTemplateEscaper(t *testing.T) {
+	html := `{"name": "{{name}}", "bio": {{>bio}}}`
 	data := map[string]interface{}{
-		"words": []string{
-			"a", "b", "c",
-		},
-		"many": map[string]interface{}{
-			"words": []string{},
-		},
+		"name": `Bruce "Batman" Wayne`,
+		"bio":  "<b>Rich</b>\n",
 	}
 
-	var exp = `<h1>Hola Mundo!</h1>`
+	var exp = `{"name": "Bruce \"Batman\" Wayne", "bio": "<b>Rich</b>\n"}`
 
-	tmpl := &Template{
-		File: bytes.NewReader([]byte(html)),
-		Data: &Data{Value: data},
-	}
+	tmpl := RenderEscaped(
+		bytes.NewReader([]byte(html)),
+		data,
+		func(path string) (io.Reader, error) {
+			return bytes.NewReader([]byte(`"{{bio}}"`)), nil
+		},
+		JSONEscape,
+	).(*Template)
 
 	Asser{t}.
 		Given(a(tmpl)).
@@ -615,77 +864,89 @@ func TestTemplateInvertedBlockDoesNotTraverseUp(t *testing.T) {
 		And(errorIs(nil))
 }
 
-func TestTemplateBlockAs(t *testing.T) {
-	{
-		var html = `{{#words as word}}{{word}}{{/words}}`
-
-		data := map[string]interface{}{
-			"words": []string{
-				"a", "b", "c",
-			},
-		}
+func TestTemplateTrustedHTML(t *testing.T) {
+	data := map[string]interface{}{
+		"html":  HTML(`<b>bold</b>`),
+		"tmpl":  template.HTML(`<i>italic</i>`),
+		"plain": `<u>under</u>`,
+		"href":  HTML(`javascript:alert(1)`),
+	}
 
+	for _, v := range []struct {
+		giv, exp   string
+		contextual bool
+		esc        Escaper
+	}{
+		{
+			giv: `{{html}}{{tmpl}}{{plain}}`,
+			exp: `<b>bold</b><i>italic</i>&lt;u&gt;under&lt;/u&gt;`,
+		},
+		{
+			giv:        `<p>{{html}}{{plain}}</p><a href="{{href}}" title="{{html}}">`,
+			exp:        `<p><b>bold</b>&lt;u&gt;under&lt;/u&gt;</p><a href="#ZbeardZ" title="&lt;b&gt;bold&lt;/b&gt;">`,
+			contextual: true,
+		},
+		{
+			giv: `"{{html}}"`,
+			exp: `"<b>bold<\/b>"`,
+			esc: EscaperFunc(func(dst, src []byte) []byte {
+				return append(dst, bytes.Replace(src, []byte("/"), []byte(`\/`), -1)...)
+			}),
+		},
+	} {
 		tmpl := &Template{
-			File: bytes.NewReader([]byte(html)),
+			File: bytes.NewReader([]byte(v.giv)),
 			Data: &Data{Value: data},
 		}
-
-		var exp = "abc"
-
-		Asser{t}.
-			Given(a(tmpl)).
-			Then(bodyEquals(exp)).
-			And(errorIs(nil))
-	}
-
-	{
-		var html = `{{#words as word}}{{word.value}}{{/words}}`
-
-		data := map[string]interface{}{
-			"words": []map[string]interface{}{
-				map[string]interface{}{
-					"value": "a",
-				},
-				map[string]interface{}{
-					"value": "b",
-				},
-				map[string]interface{}{
-					"value": "c",
-				},
-			},
+		if v.contextual {
+			tmpl.ContextualEscape()
 		}
-
-		tmpl := &Template{
-			File: bytes.NewReader([]byte(html)),
-			Data: &Data{Value: data},
+		if v.esc != nil {
+			tmpl.Escaper(v.esc)
 		}
 
-		var exp = "abc"
-
 		Asser{t}.
 			Given(a(tmpl)).
-			Then(bodyEquals(exp)).
+			Then(bodyEquals(v.exp)).
 			And(errorIs(nil))
 	}
 }
 
-func TestTemplateBlockAsKeyValue(t *testing.T) {
-	var html = `{{#words as k, v}}{{k}}:{{v}}{{/words}}`
-
+func TestTemplateRecursivePartial(t *testing.T) {
+	html := `<ul>{{#comments}}{{>comment}}{{/comments}}</ul>`
 	data := map[string]interface{}{
-		"words": map[string]interface{}{
-			"a": "b",
-			"c": "d",
-			"e": "f",
+		"comments": []interface{}{
+			map[string]interface{}{
+				"body": "a",
+				"replies": []interface{}{
+					map[string]interface{}{
+						"body": "b",
+						"replies": []interface{}{
+							map[string]interface{}{
+								"body":    "c",
+								"replies": []interface{}{},
+							},
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"body":    "d",
+				"replies": []interface{}{},
+			},
 		},
 	}
 
+	var exp = `<ul><li>a<ul><li>b<ul><li>c</li></ul></li></ul></li><li>d</li></ul>`
+
 	tmpl := &Template{
 		File: bytes.NewReader([]byte(html)),
 		Data: &Data{Value: data},
 	}
-
-	var exp = "a:bc:de:f"
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		return bytes.NewReader([]byte(
+			`<li>{{body}}{{#replies}}<ul>{{>comment}}</ul>{{/replies}}</li>`)), nil
+	})
 
 	Asser{t}.
 		Given(a(tmpl)).
@@ -693,17 +954,516 @@ func TestTemplateBlockAsKeyValue(t *testing.T) {
 		And(errorIs(nil))
 }
 
-func TestTemplateBlockAsBlockAs(t *testing.T) {
-	var html = `{{#data as k, v}}{{k}}:{{#v as key, val}}{{key}}-{{val}}{{/v}}{{/data}}`
-
+func TestTemplateRecursivePartialLeavesOmitData(t *testing.T) {
+	html := `<ul>{{#kids}}{{>node}}{{/kids}}</ul>`
 	data := map[string]interface{}{
-		"data": map[string]interface{}{
-			"a": map[string]interface{}{
-				"b": "c",
-				"d": "e",
+		"kids": []interface{}{
+			map[string]interface{}{
+				"name": "a",
+				"kids": []interface{}{
+					map[string]interface{}{"name": "b"},
+					map[string]interface{}{
+						"name": "c",
+						"kids": []interface{}{
+							map[string]interface{}{"name": "d"},
+						},
+					},
+				},
 			},
-			"f": map[string]interface{}{
-				"g": "h",
+			map[string]interface{}{"name": "e"},
+		},
+	}
+
+	// leaves without kids don't find their parent's kids
+	var exp = `<ul><li>a(<li>b()</li><li>c(<li>d()</li>)</li>)</li><li>e()</li></ul>`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		return bytes.NewReader([]byte(
+			`<li>{{name}}({{#kids}}{{>node}}{{/kids}})</li>`)), nil
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplatePartialDepthExceeded(t *testing.T) {
+	html := `<h1>{{>a}}</h1>`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: map[string]interface{}{}},
+	}
+	tmpl.MaxPartialDepth(5)
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		var p []byte
+		switch path {
+		case "a":
+			p = []byte(`{{>b}}`)
+		case "b":
+			p = []byte(`{{>c}}`)
+		case "c":
+			p = []byte(`{{>b}}`)
+
+		default:
+			t.Errorf("invalid partial %s", path)
+		}
+
+		return bytes.NewReader(p), nil
+	})
+
+	_, err := io.Copy(bytes.NewBuffer(nil), tmpl)
+
+	derr, ok := err.(*PartialDepthError)
+	if !ok {
+		t.Fatalf("expected a partial depth error, got %v", err)
+	}
+
+	var exp = "partial depth of 5 exceeded, cycle b > c > b"
+	if got := derr.Error(); exp != got {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+	if got := strings.Join(derr.Chain, " "); got != "a b c b c b" {
+		t.Errorf("expected chain a b c b c b, got %s", got)
+	}
+}
+
+func TestTemplateInheritance(t *testing.T) {
+	partials := map[string]string{
+		"layout":  `<title>{{$title}}Default{{/title}}</title>{{$body}}<p>Body</p>{{/body}}`,
+		"section": `{{<layout}}{{$title}}Section{{/title}}{{$body}}<section>{{$content}}{{/content}}</section>{{/body}}{{/layout}}`,
+		"nested":  `{{#nested}}{{$block}}You say {{fruit}}.{{/block}}{{/nested}}`,
+	}
+
+	data := map[string]interface{}{
+		"name":  "Page",
+		"fruit": "apples",
+		"nested": map[string]interface{}{
+			"fruit": "bananas",
+		},
+	}
+
+	for _, v := range []struct {
+		html string
+		exp  string
+	}{
+		{`{{<layout}}{{/layout}}`, `<title>Default</title><p>Body</p>`},
+		{`{{<layout}}{{$title}}{{name}}{{/title}}{{/layout}}!`, `<title>Page</title><p>Body</p>!`},
+		{`{{<layout}}ignored{{$body}}b{{/body}}ignored{{/layout}}`, `<title>Default</title>b`},
+		{`{{<section}}{{$content}}c{{/content}}{{/section}}`, `<title>Section</title><section>c</section>`},
+		{`{{<section}}{{$title}}t{{/title}}{{/section}}`, `<title>t</title><section></section>`},
+		{`{{<nested}}{{$block}}I say {{fruit}}.{{/block}}{{/nested}}`, `I say bananas.`},
+		{`a{{#missing}}{{<layout}}{{$title}}t{{/title}}{{/layout}}{{/missing}}b`, `ab`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: data},
+		}
+		tmpl.Partial(func(path string) (io.Reader, error) {
+			p, ok := partials[path]
+			if !ok {
+				t.Errorf("invalid partial %s", path)
+			}
+
+			return bytes.NewReader([]byte(p)), nil
+		})
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateInheritanceUnclosed(t *testing.T) {
+	html := `<h1>{{<layout}}{{$title}}t{{/title}}</h1>`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: map[string]interface{}{}},
+	}
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		return bytes.NewReader([]byte(`{{$title}}{{/title}}`)), nil
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(`<h1>`)).
+		And(errorIs(errUnclosedBlocks))
+}
+
+func TestTemplateVarsContainSpaces(t *testing.T) {
+	html := `<h1>{{a }}{{ > b }}{{ e}}</h1>`
+	data := map[string]interface{}{
+		"a": "Hello",
+		"d": "World",
+		"e": "!",
+	}
+
+	var exp = `<h1>Hello World!</h1>`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		var p []byte
+		switch path {
+		case "b":
+			p = []byte(` {{> c}}`)
+		case "c":
+			p = []byte(`{{d}}`)
+
+		default:
+			t.Error("invalid partial %s", path)
+		}
+
+		return bytes.NewReader(p), nil
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateEmptyNilUnexistBlockData(t *testing.T) {
+	html := `<h1>{{#words}}({{.}}){{/words}}</h1>`
+
+	for _, v := range []interface{}{
+		map[string]interface{}{
+			"words": []interface{}{},
+		},
+		map[string]interface{}{
+			"words": nil,
+		},
+		map[string]interface{}{
+			"badwords": []string{"foo"},
+		},
+	} {
+		var exp = `<h1></h1>`
+
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: v},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateInvertedBlock(t *testing.T) {
+	html := `<h1>{{#words}}({{.}}){{/words}}{{^words}}Hola Mundo!{{/words}}</h1>`
+
+	for _, v := range []struct {
+		data map[string]interface{}
+		exp  string
+	}{
+		{map[string]interface{}{"words": []string{"a", "b", "c"}}, `<h1>(a)(b)(c)</h1>`},
+		{map[string]interface{}{}, `<h1>Hola Mundo!</h1>`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: v.data},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateBlockElse(t *testing.T) {
+	html := `<h1>{{#words}}({{.}}){{else}}{{title}}{{/words}}</h1>`
+
+	for _, v := range []struct {
+		data map[string]interface{}
+		exp  string
+	}{
+		{map[string]interface{}{"words": []string{"a", "b", "c"}, "title": "x"}, `<h1>(a)(b)(c)</h1>`},
+		{map[string]interface{}{"words": []string{}, "title": "Hola Mundo!"}, `<h1>Hola Mundo!</h1>`},
+		{map[string]interface{}{"title": "Hola Mundo!"}, `<h1>Hola Mundo!</h1>`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: v.data},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateInvertedBlockElse(t *testing.T) {
+	html := `<h1>{{^words}}Hola Mundo!{{:else}}{{#words}}({{.}}){{/words}}{{/words}}</h1>`
+
+	for _, v := range []struct {
+		data map[string]interface{}
+		exp  string
+	}{
+		{map[string]interface{}{"words": []string{"a", "b", "c"}}, `<h1>(a)(b)(c)</h1>`},
+		{map[string]interface{}{}, `<h1>Hola Mundo!</h1>`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: v.data},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateBlockElseSkipsNestedContent(t *testing.T) {
+	html := `{{#words}}{{.}}{{else}}{{#other}}({{.}}){{/other}}{{>p}}{{/words}}`
+	data := map[string]interface{}{
+		"words": []string{"a", "b"},
+		"other": []string{"c"},
+	}
+
+	var exp = `ab`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+	tmpl.Partial(func(path string) (io.Reader, error) {
+		t.Errorf("expected partial %s to not be rendered", path)
+
+		return nil, nil
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateBlockElseErrors(t *testing.T) {
+	for _, v := range []struct {
+		html string
+		exp  string
+		err  error
+	}{
+		{`<h1>{{else}}</h1>`, `<h1>`, errElseOutsideBlock},
+		{`<h1>{{#a}}{{else}}{{else}}{{/a}}</h1>`, `<h1>`, errDuplicateElse},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: map[string]interface{}{}},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(v.err))
+	}
+}
+
+func TestTemplateIf(t *testing.T) {
+	html := `{{#if status == "active" && count > 0}}{{name}} has {{count}}{{else if !count}}{{name}} has none{{else}}{{name}} is {{status}}{{/if}}`
+
+	for _, v := range []struct {
+		data map[string]interface{}
+		exp  string
+	}{
+		{map[string]interface{}{"name": "a", "status": "active", "count": 2}, `a has 2`},
+		{map[string]interface{}{"name": "a", "status": "active", "count": 0}, `a has none`},
+		{map[string]interface{}{"name": "a", "status": "inactive", "count": 2}, `a is inactive`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: v.data},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateIfInBlock(t *testing.T) {
+	html := `{{#items}}{{#if price >= 10 || (sale && name != 'c')}}({{name}}){{/if}}{{/items}}`
+	data := map[string]interface{}{
+		"items": []map[string]interface{}{
+			{"name": "a", "price": 12.5},
+			{"name": "b", "price": 3, "sale": true},
+			{"name": "c", "price": 3, "sale": true},
+			{"name": "d", "price": 3},
+		},
+	}
+
+	var exp = `(a)(b)`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateIfSyntaxError(t *testing.T) {
+	for _, v := range []struct {
+		html   string
+		offset int
+	}{
+		{`<h1>{{#if a ==}}{{/if}}</h1>`, 14},
+		{`<h1>{{#if (a}}{{/if}}</h1>`, 12},
+		{`<h1>{{#if a "b}}{{/if}}</h1>`, 12},
+		{`<h1>{{#if}}{{/if}}</h1>`, 9},
+		{`<h1>{{#a}}{{else if a = b}}{{/a}}</h1>`, 22},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: map[string]interface{}{}},
+		}
+
+		_, err := io.Copy(bytes.NewBuffer(nil), tmpl)
+
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected a syntax error, got %v", err)
+
+			continue
+		}
+		if v.offset != serr.Offset {
+			t.Errorf("expected error at %d for %s, got %d (%s)",
+				v.offset, v.html, serr.Offset, serr)
+		}
+	}
+}
+
+func TestTemplateInvertedBlockDoesNotTraverseUp(t *testing.T) {
+	html := `<h1>{{#many.words}}({{.}}){{/many.words}}{{^many.words}}Hola Mundo!{{/many.words}}</h1>`
+	data := map[string]interface{}{
+		"words": []string{
+			"a", "b", "c",
+		},
+		"many": map[string]interface{}{
+			"words": []string{},
+		},
+	}
+
+	var exp = `<h1>Hola Mundo!</h1>`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateBlockAs(t *testing.T) {
+	{
+		var html = `{{#words as word}}{{word}}{{/words}}`
+
+		data := map[string]interface{}{
+			"words": []string{
+				"a", "b", "c",
+			},
+		}
+
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: data},
+		}
+
+		var exp = "abc"
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(exp)).
+			And(errorIs(nil))
+	}
+
+	{
+		var html = `{{#words as word}}{{word.value}}{{/words}}`
+
+		data := map[string]interface{}{
+			"words": []map[string]interface{}{
+				map[string]interface{}{
+					"value": "a",
+				},
+				map[string]interface{}{
+					"value": "b",
+				},
+				map[string]interface{}{
+					"value": "c",
+				},
+			},
+		}
+
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(html)),
+			Data: &Data{Value: data},
+		}
+
+		var exp = "abc"
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateBlockAsKeyValue(t *testing.T) {
+	var html = `{{#words as k, v}}{{k}}:{{v}}{{/words}}`
+
+	data := map[string]interface{}{
+		"words": map[string]interface{}{
+			"a": "b",
+			"c": "d",
+			"e": "f",
+		},
+	}
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+
+	var exp = "a:bc:de:f"
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateBlockAsBlockAs(t *testing.T) {
+	var html = `{{#data as k, v}}{{k}}:{{#v as key, val}}{{key}}-{{val}}{{/v}}{{/data}}`
+
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"a": map[string]interface{}{
+				"b": "c",
+				"d": "e",
+			},
+			"f": map[string]interface{}{
+				"g": "h",
 			},
 		},
 	}
@@ -749,6 +1509,182 @@ func TestTemplateBlockAsBlockAs2(t *testing.T) {
 		And(errorIs(nil))
 }
 
+func TestTemplateBlockModifiers(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []map[string]interface{}{
+			{"name": "a", "price": 10, "active": true},
+			{"name": "b", "price": 2, "active": false},
+			{"name": "c", "price": 30, "active": true},
+			{"name": "d", "price": 4, "active": true},
+		},
+		"words": []string{"a10", "a2", "a1"},
+		"post": map[string]interface{}{
+			"b": map[string]interface{}{"n": 2},
+			"a": map[string]interface{}{"n": 3},
+			"c": map[string]interface{}{"n": 1},
+		},
+	}
+
+	for _, v := range []struct {
+		html string
+		exp  string
+	}{
+		{`{{#items sort:price}}{{name}}{{/items}}`, `bdac`},
+		{`{{#items sort:price desc limit:2}}{{name}}{{/items}}`, `ca`},
+		{`{{#items sort:price limit:2 offset:1}}{{name}}{{/items}}`, `da`},
+		{`{{#items where:active sort:name desc}}{{name}}{{/items}}`, `dca`},
+		{`{{#items where:!active}}{{name}}{{/items}}`, `b`},
+		{`{{#items as item sort:item.price}}{{item.name}}{{/items}}`, `bdac`},
+		{`{{#items as item sort:item.price desc}}{{item.name}}{{/items}}`, `cadb`},
+		{`{{#items sort:item.price desc as item}}{{item.name}}{{/items}}`, `cadb`},
+		{`{{#items offset:10}}{{name}}{{else}}none{{/items}}`, `none`},
+		{`{{#words sort:. natural}}{{.}},{{/words}}`, `a1,a2,a10,`},
+		{`{{#words sort:. string}}{{.}},{{/words}}`, `a1,a10,a2,`},
+		{`{{#post as k, v sort:k desc}}{{k}}{{/post}}`, `cba`},
+		{`{{#post as k, v sort:v.n limit:2}}{{k}}{{/post}}`, `cb`},
+		{`{{#post as k, v where:v.n sort:@key}}{{k}}{{/post}}`, `abc`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: data},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateBlockModifierComparator(t *testing.T) {
+	html := `{{#words sort:. length desc}}{{.}},{{/words}}`
+	data := map[string]interface{}{
+		"words": []string{"bb", "a", "ccc"},
+	}
+
+	var exp = `ccc,bb,a,`
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+	tmpl.Comparator("length", func(a, b interface{}) int {
+		return len(a.(string)) - len(b.(string))
+	})
+
+	Asser{t}.
+		Given(a(tmpl)).
+		Then(bodyEquals(exp)).
+		And(errorIs(nil))
+}
+
+func TestTemplateBlockModifierUnknownComparator(t *testing.T) {
+	html := `<h1>{{#words sort:. length}}{{.}}{{/words}}</h1>`
+	data := map[string]interface{}{
+		"words": []string{"bb", "a", "ccc"},
+	}
+
+	tmpl := &Template{
+		File: bytes.NewReader([]byte(html)),
+		Data: &Data{Value: data},
+	}
+
+	_, err := io.Copy(bytes.NewBuffer(nil), tmpl)
+	if serr, ok := err.(*SyntaxError); !ok || serr.Offset != 13 {
+		t.Errorf("expected a syntax error at 13, got %v", err)
+	}
+}
+
+func TestTemplateBlockAsKeyValueOrdering(t *testing.T) {
+	type post struct {
+		Title  string
+		Body   string `beard:"content"`
+		Secret string `beard:"-"`
+		draft  bool
+	}
+
+	data := map[string]interface{}{
+		"ints": map[int]string{
+			10: "c",
+			2:  "b",
+			1:  "a",
+		},
+		"ordered": &orderedMap{
+			keys: []string{"z", "a", "m"},
+			values: map[string]interface{}{
+				"z": map[string]interface{}{"n": 1},
+				"a": map[string]interface{}{"n": 2},
+				"m": map[string]interface{}{"n": 3},
+			},
+		},
+		"post": post{
+			Title:  "Hello",
+			Body:   "World",
+			Secret: "!",
+			draft:  true,
+		},
+	}
+
+	for _, v := range []struct {
+		html string
+		exp  string
+	}{
+		{`{{#ints as k, v}}{{k}}:{{v}},{{/ints}}`, `1:a,2:b,10:c,`},
+		{`{{#ordered as k, v}}{{k}}:{{v.n}},{{/ordered}}`, `z:1,a:2,m:3,`},
+		{`{{#ordered as k, v sort:v.n desc limit:2}}{{k}},{{/ordered}}`, `m,a,`},
+		{`{{#post as k, v}}{{k}}:{{v}},{{/post}}`, `Title:Hello,content:World,`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: data},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
+func TestTemplateStructPathsOnlyReachIteratedFields(t *testing.T) {
+	type post struct {
+		Title  string
+		Body   string `beard:"content"`
+		Secret string `beard:"-"`
+		draft  int
+	}
+
+	data := map[string]interface{}{
+		"post": post{
+			Title:  "Hello",
+			Body:   "World",
+			Secret: "!",
+			draft:  2,
+		},
+	}
+
+	for _, v := range []struct {
+		html string
+		exp  string
+	}{
+		{`{{post.Title}} {{post.content}}`, `Hello World`},
+		{`[{{post.Body}}]`, `[]`},
+		{`[{{post.Secret}}]`, `[]`},
+		{`[{{post.draft}}]`, `[]`},
+		{`{{^post.draft}}none{{/post.draft}}`, `none`},
+	} {
+		tmpl := &Template{
+			File: bytes.NewReader([]byte(v.html)),
+			Data: &Data{Value: data},
+		}
+
+		Asser{t}.
+			Given(a(tmpl)).
+			Then(bodyEquals(v.exp)).
+			And(errorIs(nil))
+	}
+}
+
 func TestTemplateErrorsUnclosedBlock(t *testing.T) {
 	html := `<h1>{{#words}}({{.}})</h1>`
 	data := map[string]interface{}{
@@ -886,6 +1822,70 @@ var bodyEquals = func(exp string) StepFunc {
 	}
 }
 
+func TestTemplateErrorOffset(t *testing.T) {
+	errLambda := errors.New("lambda error")
+
+	files := map[string]*bytes.Reader{}
+	file := func(name, src string) *bytes.Reader {
+		files[name] = bytes.NewReader([]byte(src))
+
+		return files[name]
+	}
+	partials := func(path string) (io.Reader, error) {
+		switch path {
+		case "card":
+			return file("card", "<div>\n  {{fail}}</div>"), nil
+		case "base":
+			return file("base", "<h1>{{$title}}{{/title}}</h1>"), nil
+		}
+
+		return nil, errors.New("not found")
+	}
+	data := map[string]interface{}{
+		"fail": Lambda(func(context.Context) (interface{}, error) {
+			return nil, errLambda
+		}),
+	}
+
+	for _, v := range []struct {
+		html   string
+		layout string
+		file   string
+		offset int
+	}{
+		{`<p>{{>missing}}</p>`, "", "page", 3},
+		{`<p>{{>card}}</p>`, "", "card", 8},
+		{`a{{#content_for s}}bc{{fail}}{{/content_for}}`, "", "page", 21},
+		{`{{<base}}{{$title}}x{{fail}}{{/title}}{{/base}}`, "", "page", 20},
+		{`ab{{fail}}`, `<html>{{>yield}}</html>`, "page", 2},
+		{`<p>{{#if fail}}{{/if}}</p>`, `<html>{{>yield}}</html>`, "page", 3},
+	} {
+		var r io.Reader = Render(file("page", v.html), data, partials)
+		if v.layout != "" {
+			r = RenderInLayouts(file("page", v.html), data, partials,
+				file("layout", v.layout))
+		}
+		tmpl := r.(*Template)
+
+		if _, err := ioutil.ReadAll(tmpl); err == nil {
+			t.Errorf("expected an error for %s", v.html)
+
+			continue
+		}
+
+		f, offset, ok := tmpl.ErrorOffset()
+		if !ok {
+			t.Errorf("expected an error offset for %s", v.html)
+
+			continue
+		}
+		if f != files[v.file] || offset != v.offset {
+			t.Errorf("expected offset %d of %s for %s, got %d", v.offset, v.file,
+				v.html, offset)
+		}
+	}
+}
+
 var errorIs = func(exp error) StepFunc {
 	return func(t testing.TB, ctx Context) {
 		var err = ctx.Get("err")